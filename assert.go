@@ -0,0 +1,369 @@
+package httpmatter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// assertionPrefix starts an inline assertion line, as in HttpYac
+const assertionPrefix = "??"
+
+// Assertion is an inline assertion of a fixture, a line like
+//
+//	?? status == 200
+//	?? header Content-Type contains json
+//	?? body $.data.id exists
+//
+// It is checked against the response the request got, see CheckAssertions
+type Assertion struct {
+	File string
+	Line int
+	// Text is the assertion without the leading "??"
+	Text string
+	// place and after tell where a line taken out of the content was,
+	// so writing the fixture puts it back there
+	place assertionPlace
+	after int
+}
+
+// assertionPlace is where an assertion line is in a fixture
+type assertionPlace int
+
+const (
+	inFront assertionPlace = iota
+	// inHeaders lines are after the request or status line,
+	// after counts the message lines before them
+	inHeaders
+	belowBody
+)
+
+func (a Assertion) String() string {
+	return fmt.Sprintf("%s:%d: %s %s", a.File, a.Line, assertionPrefix, a.Text)
+}
+
+// Assertions returns the inline assertions of the fixture in file order
+func (m *Matter) Assertions() []Assertion {
+	return m.assertions
+}
+
+// CheckAssertions checks the inline assertions against the response.
+// Templates in an assertion are rendered first, so it can use variables.
+// The body is left readable. It returns one error per failed assertion,
+// joined, each naming the fixture file and line
+func (m *Matter) CheckAssertions(resp *http.Response) error {
+	var errs []error
+	for _, a := range m.assertions {
+		text, err := executeTemplate(m.config.TemplateConverter(a.Text), m)
+		if err == nil && resp == nil {
+			err = fmt.Errorf("no response")
+		}
+		if err == nil {
+			err = checkAssertion(string(text), resp)
+		}
+		if err != nil {
+			errs = append(errs, ErrAssertion().
+				WithData("at", fmt.Sprintf("%s:%d", a.File, a.Line)).
+				WithData("assertion", a.Text).
+				WithError(err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// parseAssertions takes the assertion lines out of the front matter and
+// the content. As in HttpYac, assertions in the content are the ones among
+// the headers and the ones below the body, separated from it by a blank
+// line. Lines of the body are never taken. Line numbers count from the
+// start of the file
+func parseAssertions(file, front, content string) ([]Assertion, string) {
+	var assertions []Assertion
+	for i, line := range strings.SplitAfter(front, "\n") {
+		if text, ok := cutAssertion(line); ok {
+			assertions = append(assertions, Assertion{File: file, Line: i + 1, Text: text})
+		}
+	}
+	offset := strings.Count(front, "\n")
+	fronted := len(assertions)
+	take := func(i int, text string, place assertionPlace, after int) {
+		assertions = append(assertions, Assertion{File: file, Line: offset + i + 1, Text: text, place: place, after: after})
+	}
+
+	lines := strings.SplitAfter(content, "\n")
+	kept := strings.Builder{}
+	// the blank line ending the headers, or the end of the content
+	separator := len(lines)
+	for i, line := range lines {
+		if i > 0 && strings.TrimSpace(line) == "" {
+			separator = i
+			break
+		}
+		if text, ok := cutAssertion(line); ok && i > 0 {
+			take(i, text, inHeaders, i-len(assertions)+fronted)
+			continue
+		}
+		kept.WriteString(line)
+	}
+	if separator == len(lines) {
+		return assertions, kept.String()
+	}
+
+	// the assertions below the body start after a blank line,
+	// the headers end with one as well
+	below := len(lines)
+	for below > separator+1 {
+		line := lines[below-1]
+		if _, ok := cutAssertion(line); !ok && strings.TrimSpace(line) != "" {
+			break
+		}
+		below--
+	}
+	if below > separator+1 && below < len(lines) && strings.TrimSpace(lines[below]) != "" {
+		// assertion lines right below the body text are body
+		for below < len(lines) && strings.TrimSpace(lines[below]) != "" {
+			below++
+		}
+	}
+	found := false
+	for i := below; i < len(lines); i++ {
+		if text, ok := cutAssertion(lines[i]); ok {
+			take(i, text, belowBody, 0)
+			found = true
+		}
+	}
+	for _, line := range lines[separator:below] {
+		kept.WriteString(line)
+	}
+	if !found {
+		for _, line := range lines[below:] {
+			kept.WriteString(line)
+		}
+		return assertions, kept.String()
+	}
+	// blank lines separating the assertions below the body are not body
+	eol := "\n"
+	if strings.Contains(content, "\r\n") {
+		eol = "\r\n"
+	}
+	return assertions, strings.TrimRight(kept.String(), "\r\n") + eol
+}
+
+func cutAssertion(line string) (string, bool) {
+	text, ok := strings.CutPrefix(strings.TrimSpace(line), assertionPrefix)
+	if !ok {
+		return "", false
+	}
+	return strings.TrimSpace(text), true
+}
+
+// contentWithAssertions returns the content with the assertions taken
+// out of it back where they were, so writing the fixture keeps them there.
+// Lines among the headers stay among the headers when these change
+func (m *Matter) contentWithAssertions() string {
+	var below []string
+	for _, a := range m.assertions {
+		if a.place == belowBody {
+			below = append(below, a.Text)
+		}
+	}
+	eol := "\n"
+	if strings.Contains(m.content, "\r\n") {
+		eol = "\r\n"
+	}
+	line := func(text string) string {
+		return assertionPrefix + " " + text + eol
+	}
+	lines := strings.SplitAfter(m.content, "\n")
+	headers := len(lines)
+	for i, l := range lines {
+		if i > 0 && strings.TrimSpace(l) == "" {
+			headers = i
+			break
+		}
+	}
+	out := strings.Builder{}
+	for i, l := range lines {
+		out.WriteString(l)
+		for _, a := range m.assertions {
+			if a.place != inHeaders || min(max(a.after, 1), headers) != i+1 {
+				continue
+			}
+			if !strings.HasSuffix(out.String(), "\n") {
+				out.WriteString(eol)
+			}
+			out.WriteString(line(a.Text))
+		}
+	}
+	if len(below) == 0 {
+		return out.String()
+	}
+	content := strings.TrimRight(out.String(), "\r\n") + eol + eol
+	for _, text := range below {
+		content += line(text)
+	}
+	return content
+}
+
+// checkAssertion checks "<target> [argument] <operator> [expected]", where
+// the target is status, header <name> or body [<query>], see queryBody
+func checkAssertion(text string, resp *http.Response) error {
+	target, rest := cutField(text)
+	var actual any
+	var lookupErr error
+	switch target {
+	case "status":
+		actual = resp.StatusCode
+	case "header":
+		var name string
+		name, rest = cutField(rest)
+		if name == "" {
+			return fmt.Errorf("header needs a name")
+		}
+		values := resp.Header.Values(name)
+		if len(values) == 0 {
+			lookupErr = fmt.Errorf("header %s not found", name)
+		}
+		actual = strings.Join(values, ", ")
+	case "body":
+		expr := ""
+		if field, after := cutField(rest); field != "" && !isAssertOperator(field) {
+			expr, rest = field, after
+		}
+		body, err := peekBody(&resp.Body)
+		if err != nil {
+			return err
+		}
+		actual, lookupErr = queryBody(body, expr)
+	default:
+		return fmt.Errorf("unknown target %q, use status, header or body", target)
+	}
+
+	op, expected := cutField(rest)
+	expected = strings.Trim(expected, `"'`)
+	switch op {
+	case "exists":
+		return lookupErr
+	case "!exists":
+		if lookupErr == nil {
+			return fmt.Errorf("got %s", assertText(actual))
+		}
+		return nil
+	case "":
+		return fmt.Errorf("missing operator")
+	}
+	if lookupErr != nil {
+		return lookupErr
+	}
+	ok, err := compareAssertion(op, actual, expected)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("got %s", assertText(actual))
+	}
+	return nil
+}
+
+// compareAssertion applies the operator, comparisons like == compare
+// numerically when both sides are numbers
+func compareAssertion(op string, actual any, expected string) (bool, error) {
+	text := assertText(actual)
+	got, gotErr := strconv.ParseFloat(text, 64)
+	want, wantErr := strconv.ParseFloat(expected, 64)
+	numeric := gotErr == nil && wantErr == nil
+	switch op {
+	case "==":
+		return numeric && got == want || text == expected, nil
+	case "!=":
+		return !(numeric && got == want || text == expected), nil
+	case "<", "<=", ">", ">=":
+		if !numeric {
+			return false, fmt.Errorf("%s needs numbers, got %s %s %s", op, text, op, expected)
+		}
+		switch op {
+		case "<":
+			return got < want, nil
+		case "<=":
+			return got <= want, nil
+		case ">":
+			return got > want, nil
+		}
+		return got >= want, nil
+	case "contains", "includes":
+		return strings.Contains(text, expected), nil
+	case "startsWith":
+		return strings.HasPrefix(text, expected), nil
+	case "endsWith":
+		return strings.HasSuffix(text, expected), nil
+	case "matches":
+		re, err := regexp.Compile(expected)
+		if err != nil {
+			return false, err
+		}
+		return re.MatchString(text), nil
+	case "isTrue":
+		return text == "true", nil
+	case "isFalse":
+		return text == "false", nil
+	case "isNumber":
+		switch actual.(type) {
+		case json.Number, int:
+			return true, nil
+		}
+		return false, nil
+	case "isString":
+		_, ok := actual.(string)
+		return ok, nil
+	case "isBoolean":
+		_, ok := actual.(bool)
+		return ok, nil
+	case "isArray":
+		_, ok := actual.([]any)
+		return ok, nil
+	}
+	return false, fmt.Errorf("unknown operator %q", op)
+}
+
+// assertOperators are the operators of an assertion, as in HttpYac
+var assertOperators = []string{
+	"exists", "!exists", "==", "!=", "<", "<=", ">", ">=",
+	"contains", "includes", "startsWith", "endsWith", "matches",
+	"isTrue", "isFalse", "isNumber", "isString", "isBoolean", "isArray",
+}
+
+func isAssertOperator(field string) bool {
+	return slices.Contains(assertOperators, field)
+}
+
+// assertText formats a value as the assertion compares it
+func assertText(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case nil:
+		return "null"
+	case json.Number:
+		return v.String()
+	case int:
+		return strconv.Itoa(v)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// cutField returns the first whitespace separated field and the rest
+func cutField(s string) (string, string) {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexAny(s, " \t"); i != -1 {
+		return s[:i], strings.TrimSpace(s[i:])
+	}
+	return s, ""
+}
@@ -0,0 +1,201 @@
+package httpmatter
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssertionsParsed(t *testing.T) {
+	must := require.New(t)
+	req, err := Request("assert", "request_create_user", WithVariables(map[string]any{"name": "Jane"}))
+	must.NoError(err)
+
+	var lines []int
+	var texts []string
+	for _, a := range req.Assertions() {
+		lines = append(lines, a.Line)
+		texts = append(texts, a.Text)
+	}
+	must.Equal([]int{4, 10, 11, 12}, lines)
+	must.Equal([]string{
+		"status == 201",
+		"header Location startsWith /users/",
+		"body $.name == {{name}}",
+		"body $.id matches ^[0-9a-f-]{36}$",
+	}, texts)
+	must.True(strings.HasSuffix(req.Assertions()[1].String(),
+		"testdata/assert/request_create_user.http:10: ?? header Location startsWith /users/"))
+
+	body, err := req.BodyString()
+	must.NoError(err)
+	must.Equal("{\"name\": \"Jane\", \"email\": \"jane@example.com\"}\n", body)
+}
+
+func TestCheckAssertion(t *testing.T) {
+	resp := func() *http.Response {
+		return &http.Response{
+			StatusCode: http.StatusCreated,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"id": 7, "name": "Jane", "tags": ["a"], "active": true}`)),
+		}
+	}
+	tests := []struct {
+		assertion string
+		err       string
+	}{
+		{assertion: "status == 201"},
+		{assertion: "status != 200"},
+		{assertion: "status < 300"},
+		{assertion: "status >= 400", err: "got 201"},
+		{assertion: "header Content-Type contains json"},
+		{assertion: "header content-type == 'application/json'"},
+		{assertion: "header X-Missing exists", err: "header X-Missing not found"},
+		{assertion: "header X-Missing !exists"},
+		{assertion: "body $.id == 7.0"},
+		{assertion: "body $.id isNumber"},
+		{assertion: "body $.name == \"Jane\""},
+		{assertion: "body $.name startsWith Ja"},
+		{assertion: "body $.name endsWith x", err: "got Jane"},
+		{assertion: "body $.tags isArray"},
+		{assertion: "body $.active isTrue"},
+		{assertion: "body $.missing exists", err: `key "missing" not found`},
+		{assertion: "body contains Jane"},
+		{assertion: "body $.name > 3", err: "> needs numbers"},
+		{assertion: "body $.name", err: "missing operator"},
+		{assertion: "body $.name equals Jane", err: `unknown operator "equals"`},
+		{assertion: "duration < 100", err: `unknown target "duration"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.assertion, func(t *testing.T) {
+			r := resp()
+			err := checkAssertion(tt.assertion, r)
+			if tt.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, tt.err)
+			}
+			body, _ := io.ReadAll(r.Body)
+			require.NotEmpty(t, body, "body must stay readable")
+		})
+	}
+}
+
+func TestServeFixtureAssertions(t *testing.T) {
+	must := require.New(t)
+	vars := WithVariables(map[string]any{"name": "Jane"})
+	ServeFixture(t, usersHandler(http.StatusCreated, ""), "assert", "request_create_user", "response_create_user", vars)
+
+	tb := &recordingTB{}
+	ServeFixture(tb, usersHandler(http.StatusCreated, "!"), "assert", "request_create_user", "response_create_user", vars)
+	must.Len(tb.errors, 2)
+	must.Contains(tb.errors[1], "response of request_create_user fails the assertions of request_create_user")
+	must.Contains(tb.errors[1], "testdata/assert/request_create_user.http:11 ")
+	must.Contains(tb.errors[1], "error:got Jane!")
+}
+
+func TestAssertionsKeptOnWrite(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		// written is the content written back after the headers changed
+		written string
+	}{
+		{
+			name:    "below the request line",
+			content: "GET / HTTP/1.1\n\n?? status == 200\n",
+			written: "GET / HTTP/1.1\n\n?? status == 200\n",
+		},
+		{
+			name:    "among headers and below the body",
+			content: "POST / HTTP/1.1\nAccept: text/plain\n?? status == 200\nX-Trace: 1\n\nbody\n\n?? body contains body\n",
+			written: "POST / HTTP/1.1\nAccept: text/plain\n?? status == 200\n\nbody\n\n?? body contains body\n",
+		},
+		{
+			name:    "crlf",
+			content: "GET / HTTP/1.1\r\n?? status == 200\r\n\r\n",
+			written: "GET / HTTP/1.1\r\n?? status == 200\r\n\r\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMatter("assert", "request_create_user")
+			m.front = "///\n// @name create_user\n///\n"
+			m.assertions, m.content = parseAssertions("x.http", m.front, tt.content)
+			require.NotContains(t, m.content, assertionPrefix)
+			m.content = strings.Replace(m.content, "X-Trace: 1\n", "", 1)
+			require.Equal(t, tt.written, m.contentWithAssertions())
+		})
+	}
+}
+
+func TestAssertionsNotTakenFromBody(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		assertions []string
+		body       string
+	}{
+		{
+			name:       "among headers",
+			content:    "GET / HTTP/1.1\n?? status == 200\nAccept: text/plain\n\nbody\n",
+			assertions: []string{"status == 200"},
+			body:       "GET / HTTP/1.1\nAccept: text/plain\n\nbody\n",
+		},
+		{
+			name:    "inside the body",
+			content: "POST / HTTP/1.1\nContent-Type: text/plain\n\nline one\n?? not an assertion\nline three\n",
+			body:    "POST / HTTP/1.1\nContent-Type: text/plain\n\nline one\n?? not an assertion\nline three\n",
+		},
+		{
+			name:    "right below the body",
+			content: "POST / HTTP/1.1\n\nline one\n?? still body\n",
+			body:    "POST / HTTP/1.1\n\nline one\n?? still body\n",
+		},
+		{
+			name:    "no line break at the end",
+			content: "POST / HTTP/1.1\n\nline one",
+			body:    "POST / HTTP/1.1\n\nline one",
+		},
+		{
+			name:       "below the body",
+			content:    "POST / HTTP/1.1\n\nline one\n?? still body\n\n?? status == 200\n\n?? body contains one\n",
+			assertions: []string{"status == 200", "body contains one"},
+			body:       "POST / HTTP/1.1\n\nline one\n?? still body\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertions, body := parseAssertions("x.http", "", tt.content)
+			var texts []string
+			for _, a := range assertions {
+				texts = append(texts, a.Text)
+			}
+			require.Equal(t, tt.assertions, texts)
+			require.Equal(t, tt.body, body)
+		})
+	}
+}
+
+func TestHTTPAssertions(t *testing.T) {
+	must := require.New(t)
+	tb := &recordingTB{}
+	h := NewHTTP(tb, "assert").
+		Add("request_create_user", "response_ok").
+		Respond(nil)
+	h.vars["name"] = "Jane"
+	h.Init()
+	defer h.Destroy()
+
+	resp, err := h.Client().Post("https://api.example.com/users", "application/json",
+		strings.NewReader(`{"name": "Jane", "email": "jane@example.com"}`))
+	must.NoError(err)
+	must.Equal(http.StatusOK, resp.StatusCode)
+	must.Len(tb.errors, 1)
+	must.Contains(tb.errors[0], "response_ok fails the assertions of request_create_user")
+	must.Contains(tb.errors[0], "testdata/assert/request_create_user.http:4 ")
+	must.Contains(tb.errors[0], "error:got 200")
+}
@@ -0,0 +1,132 @@
+package httpmatter
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// maxLoggedCalls is how many calls are kept for the report, so
+// long running handlers do not grow without limit
+const maxLoggedCalls = 100
+
+// noTripKey counts the calls matching no trip at all under one key,
+// so counts only grows with the trips added
+const noTripKey = "requests matching no trip"
+
+// call is a request which reached the mock, trip is nil when no
+// pending trip matched. The key is the one of the trip, or of the used up
+// trip the request matches, so extra calls count against it, or noTripKey
+type call struct {
+	key     string
+	request string
+	trip    *trip
+	resp    string
+}
+
+func (c call) String() string {
+	if c.trip == nil {
+		return c.request + " -> no trip"
+	}
+	return fmt.Sprintf("%s -> %s / %s", c.key, c.trip.req.Name, c.resp)
+}
+
+// logCall counts the call and keeps it for the report, only the last
+// maxLoggedCalls are kept. h.mu must be held
+func (h *HTTP) logCall(c *call) {
+	h.counts[c.key]++
+	h.calls = append(h.calls, c)
+	if len(h.calls) > maxLoggedCalls {
+		h.calls = slices.Delete(h.calls, 0, 1)
+		h.dropped++
+	}
+}
+
+// usedKey returns the key of the first trip the request matches, used
+// up or not, or else noTripKey
+func (h *HTTP) usedKey(r *http.Request, anyHost bool) string {
+	body, err := peekBody(&r.Body)
+	if err != nil {
+		return noTripKey
+	}
+	for _, trip := range h.trips {
+		if trip.matches(r, body, nil, anyHost) {
+			return h.toKey(trip.req)
+		}
+	}
+	return noTripKey
+}
+
+// usageReport lists the trips which responded less often than they must,
+// the keys which got more calls than their trips may take and every call
+// in order, the last maxLoggedCalls of them. It is empty when every trip
+// was used as added. h.mu must be held
+func (h *HTTP) usageReport() string {
+	var unused, few []string
+	capacity := make(map[string]int)
+	var keys []string
+	for _, trip := range h.trips {
+		key := h.toKey(trip.req)
+		if _, ok := capacity[key]; !ok {
+			keys = append(keys, key)
+		}
+		if trip.max == unlimited || capacity[key] == unlimited {
+			capacity[key] = unlimited
+		} else {
+			capacity[key] += trip.max
+		}
+		switch {
+		case trip.calls >= trip.min:
+		case trip.calls == 0:
+			unused = append(unused, fmt.Sprintf("%s (%s), expected %s", trip.req.Name, key, trip.expected()))
+		default:
+			few = append(few, fmt.Sprintf("%s (%s): %d calls, expected %s", trip.req.Name, key, trip.calls, trip.expected()))
+		}
+	}
+	var extra []string
+	for _, key := range append(keys, noTripKey) {
+		if capacity[key] != unlimited && h.counts[key] > capacity[key] {
+			extra = append(extra, fmt.Sprintf("%s: %d calls, expected at most %d", key, h.counts[key], capacity[key]))
+		}
+	}
+	if len(unused) == 0 && len(few) == 0 && len(extra) == 0 {
+		return ""
+	}
+
+	report := strings.Builder{}
+	section := func(title string, lines []string) {
+		if len(lines) == 0 {
+			return
+		}
+		report.WriteString(title + ":\n")
+		for _, line := range lines {
+			report.WriteString("  " + line + "\n")
+		}
+	}
+	section("unused trips", unused)
+	section("too few calls", few)
+	section("extra calls", extra)
+	report.WriteString("calls in order:\n")
+	if len(h.calls) == 0 {
+		report.WriteString("  none\n")
+	}
+	if h.dropped > 0 {
+		fmt.Fprintf(&report, "  ... %d earlier calls\n", h.dropped)
+	}
+	for i, c := range h.calls {
+		fmt.Fprintf(&report, "  %d. %s\n", h.dropped+i+1, c)
+	}
+	return report.String()
+}
+
+// expected describes how often the trip must respond
+func (t *trip) expected() string {
+	switch {
+	case t.max == unlimited:
+		return fmt.Sprintf("at least %d", t.min)
+	case t.min == t.max:
+		return fmt.Sprintf("exactly %d", t.min)
+	}
+	return fmt.Sprintf("%d to %d", t.min, t.max)
+}
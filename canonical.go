@@ -0,0 +1,175 @@
+package httpmatter
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// DefaultDropHeaders are the headers the canonical writer leaves out when
+// Config.DropHeaders is empty: hop-by-hop headers, which only describe the
+// connection, and volatile headers, which change on every response.
+// Content-Length is computed again when a fixture is parsed
+var DefaultDropHeaders = []string{
+	// hop-by-hop
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+	// volatile
+	"Age",
+	"Content-Length",
+	"Date",
+}
+
+// Canonicalize writes fixtures in a stable form which gives small diffs
+// when they are recorded again: the dropped headers are left out together
+// with the ones listed by Connection, the other headers are sorted by name,
+// JSON, XML and form bodies are pretty printed with sorted keys and lines
+// end with \n. Bodies which cannot be parsed, or forms holding {{...}},
+// are left as they are. With no drop headers DefaultDropHeaders is used
+func Canonicalize(drop ...string) Sanitizer {
+	if len(drop) == 0 {
+		drop = DefaultDropHeaders
+	}
+	return func(msg *Message) error {
+		dropped := make(map[string]bool)
+		for _, name := range drop {
+			dropped[http.CanonicalHeaderKey(name)] = true
+		}
+		for _, name := range strings.Split(msg.Get("Connection"), ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				dropped[http.CanonicalHeaderKey(name)] = true
+			}
+		}
+		headers := msg.Headers[:0]
+		for _, header := range msg.Headers {
+			header.Name = http.CanonicalHeaderKey(header.Name)
+			if !dropped[header.Name] {
+				headers = append(headers, header)
+			}
+		}
+		// Stable, so the values of one header keep their order
+		slices.SortStableFunc(headers, func(a, b Header) int {
+			return strings.Compare(a.Name, b.Name)
+		})
+		msg.Headers = headers
+
+		contentType := strings.ToLower(msg.Get("Content-Type"))
+		switch {
+		case len(bytes.TrimSpace(msg.Body)) == 0:
+		case strings.Contains(contentType, "json") || (contentType == "" && json.Valid(msg.Body)):
+			if body, err := canonicalJSON(msg.Body); err == nil {
+				msg.Body = body
+			}
+		case strings.Contains(contentType, "xml"):
+			if body, err := canonicalXML(msg.Body); err == nil {
+				msg.Body = body
+			}
+		case strings.HasPrefix(contentType, "application/x-www-form-urlencoded"):
+			if body, err := canonicalForm(msg.Body); err == nil {
+				msg.Body = body
+			}
+		case isText(contentType):
+			msg.Body = bytes.ReplaceAll(msg.Body, []byte("\r\n"), []byte("\n"))
+		}
+		msg.eol = "\n"
+		return nil
+	}
+}
+
+// isText reports whether line endings of the body can be changed
+func isText(contentType string) bool {
+	return strings.HasPrefix(contentType, "text/") ||
+		strings.Contains(contentType, "javascript") ||
+		strings.Contains(contentType, "yaml")
+}
+
+// canonicalJSON indents with two spaces and sorts object keys
+func canonicalJSON(body []byte) ([]byte, error) {
+	var v any
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	out := bytes.Buffer{}
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// canonicalXML indents with two spaces and drops whitespace between
+// elements, prefixes are kept as they are
+func canonicalXML(body []byte) ([]byte, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	out := bytes.Buffer{}
+	encoder := xml.NewEncoder(&out)
+	encoder.Indent("", "  ")
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			t.Name = prefixed(t.Name)
+			for i := range t.Attr {
+				t.Attr[i].Name = prefixed(t.Attr[i].Name)
+			}
+			token = t
+		case xml.EndElement:
+			t.Name = prefixed(t.Name)
+			token = t
+		case xml.CharData:
+			if len(bytes.TrimSpace(t)) == 0 {
+				continue
+			}
+		}
+		if err := encoder.EncodeToken(xml.CopyToken(token)); err != nil {
+			return nil, err
+		}
+	}
+	if err := encoder.Flush(); err != nil {
+		return nil, err
+	}
+	out.WriteString("\n")
+	return out.Bytes(), nil
+}
+
+// prefixed keeps a raw prefix like soap:Envelope as it is,
+// the encoder would turn the prefix into a xmlns attribute
+func prefixed(name xml.Name) xml.Name {
+	if name.Space == "" {
+		return name
+	}
+	return xml.Name{Local: name.Space + ":" + name.Local}
+}
+
+// canonicalForm sorts the fields by name, values keep their order
+func canonicalForm(body []byte) ([]byte, error) {
+	if bytes.Contains(body, []byte("{{")) {
+		return body, nil
+	}
+	values, err := url.ParseQuery(strings.TrimSpace(string(body)))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(values.Encode()), nil
+}
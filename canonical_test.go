@@ -0,0 +1,129 @@
+package httpmatter
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{
+			name: "headers sorted and dropped",
+			content: "HTTP/1.1 200 OK\r\n" +
+				"x-trace: b\r\n" +
+				"Date: Mon, 02 Jan 2006 15:04:05 GMT\r\n" +
+				"Connection: keep-alive, X-Hop\r\n" +
+				"X-Hop: 1\r\n" +
+				"Transfer-Encoding: chunked\r\n" +
+				"Set-Cookie: b=2\r\n" +
+				"Accept: a\r\n" +
+				"Set-Cookie: a=1\r\n" +
+				"\r\n",
+			expected: "HTTP/1.1 200 OK\n" +
+				"Accept: a\n" +
+				"Set-Cookie: b=2\n" +
+				"Set-Cookie: a=1\n" +
+				"X-Trace: b\n" +
+				"\n",
+		},
+		{
+			name: "json",
+			content: "HTTP/1.1 200 OK\r\nContent-Type: application/json; charset=utf-8\r\nContent-Length: 44\r\n\r\n" +
+				`{"b":[1,2.50],"a":{"d":"<x>","c":null}}`,
+			expected: "HTTP/1.1 200 OK\nContent-Type: application/json; charset=utf-8\n\n" +
+				"{\n  \"a\": {\n    \"c\": null,\n    \"d\": \"<x>\"\n  },\n  \"b\": [\n    1,\n    2.50\n  ]\n}\n",
+		},
+		{
+			name: "json with template",
+			content: "HTTP/1.1 200 OK\r\nContent-Type: application/json\r\n\r\n" +
+				`{"id":{{id}}}`,
+			expected: "HTTP/1.1 200 OK\nContent-Type: application/json\n\n" +
+				`{"id":{{id}}}`,
+		},
+		{
+			name: "xml",
+			content: "HTTP/1.1 200 OK\r\nContent-Type: text/xml\r\n\r\n" +
+				`<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body><id a="1">7</id></soap:Body></soap:Envelope>`,
+			expected: "HTTP/1.1 200 OK\nContent-Type: text/xml\n\n" +
+				"<soap:Envelope xmlns:soap=\"http://schemas.xmlsoap.org/soap/envelope/\">\n" +
+				"  <soap:Body>\n" +
+				"    <id a=\"1\">7</id>\n" +
+				"  </soap:Body>\n" +
+				"</soap:Envelope>\n",
+		},
+		{
+			name:     "form",
+			content:  "POST https://api.example.com/login HTTP/1.1\r\nContent-Type: application/x-www-form-urlencoded\r\n\r\nz=1&a=2&z=0",
+			expected: "POST https://api.example.com/login HTTP/1.1\nContent-Type: application/x-www-form-urlencoded\n\na=2&z=1&z=0",
+		},
+		{
+			name:     "text line endings",
+			content:  "HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\n\r\na\r\nb\r\n",
+			expected: "HTTP/1.1 200 OK\nContent-Type: text/plain\n\na\nb\n",
+		},
+		{
+			name:     "binary",
+			content:  "HTTP/1.1 200 OK\r\nContent-Type: application/octet-stream\r\n\r\na\r\nb",
+			expected: "HTTP/1.1 200 OK\nContent-Type: application/octet-stream\n\na\r\nb",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := ParseMessage(tt.content)
+			require.NoError(t, Canonicalize()(msg))
+			require.Equal(t, tt.expected, msg.String())
+		})
+	}
+}
+
+func TestCanonicalSave(t *testing.T) {
+	must := require.New(t)
+	namespace := tempNamespace(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-B", "2")
+		w.Header().Set("X-A", "1")
+		// Flushing before the end makes the response chunked
+		fmt.Fprint(w, `{"z":1,`)
+		w.(http.Flusher).Flush()
+		fmt.Fprint(w, `"a":2}`)
+	}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	must.NoError(err)
+	must.Equal([]string{"chunked"}, resp.TransferEncoding)
+
+	respm := NewResponseMatter(namespace, "response_chunked")
+	must.NoError(respm.WithOptions(WithCanonical()))
+	must.NoError(respm.Dump(resp))
+	must.NoError(respm.Save())
+
+	b, err := os.ReadFile(filepath.Join(config.BaseDir, namespace, "response_chunked.http"))
+	must.NoError(err)
+	must.Equal("HTTP/1.1 200 OK\nContent-Type: application/json\nX-A: 1\nX-B: 2\n\n{\n  \"a\": 2,\n  \"z\": 1\n}\n", string(b))
+
+	read, err := Response(namespace, "response_chunked")
+	must.NoError(err)
+	body, err := read.BodyString()
+	must.NoError(err)
+	must.JSONEq(`{"a":2,"z":1}`, body)
+
+	// The dump keeps the response readable
+	rest, err := io.ReadAll(resp.Body)
+	must.NoError(err)
+	must.Equal(`{"z":1,"a":2}`, string(rest))
+}
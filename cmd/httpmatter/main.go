@@ -0,0 +1,110 @@
+// Command httpmatter uses httpmatter fixtures outside of go test.
+//
+//	httpmatter serve [flags]     serve fixtures as a mock server
+//	httpmatter verify [flags]    verify a provider against the fixtures
+//	httpmatter run [flags] path  send request files and print the responses
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/therewardstore/httpmatter"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	var err error
+	switch os.Args[1] {
+	case "serve":
+		err = serve(os.Args[2:])
+	case "verify":
+		err = verify(os.Args[2:])
+	case "run":
+		err = run(os.Args[2:])
+	case "-h", "-help", "--help", "help":
+		usage()
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprint(os.Stderr, `usage: httpmatter <command> [flags]
+
+commands:
+  serve    serve fixtures as a mock server
+  verify   run request fixtures against a provider and compare the
+           answers with their response fixtures
+  run      send the request files of a file or directory in order
+           and print the responses
+
+run "httpmatter <command> -h" for the flags of a command
+`)
+}
+
+// fixtureFlags are the flags every command uses to find fixtures
+type fixtureFlags struct {
+	dir        string
+	namespaces string
+	ext        string
+	envName    string
+	envExt     string
+}
+
+func (f *fixtureFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.dir, "dir", "testdata", "base directory of the fixtures")
+	fs.StringVar(&f.namespaces, "ns", "", "comma separated namespaces (default all directories in -dir)")
+	fs.StringVar(&f.ext, "ext", ".http", "file extension of the fixtures")
+	fs.StringVar(&f.envName, "env", "", "env file name inside each namespace")
+	fs.StringVar(&f.envExt, "env-ext", ".env", "env file extension")
+}
+
+// init configures httpmatter and returns the namespaces to use
+func (f *fixtureFlags) init() ([]string, error) {
+	err := httpmatter.Init(&httpmatter.Config{
+		BaseDir:          f.dir,
+		FileExtension:    f.ext,
+		EnvFileName:      f.envName,
+		EnvFileExtension: f.envExt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if f.namespaces != "" {
+		return strings.Split(f.namespaces, ","), nil
+	}
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return nil, err
+	}
+	var namespaces []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			namespaces = append(namespaces, entry.Name())
+		}
+	}
+	return namespaces, nil
+}
+
+// paths returns the namespace directories
+func (f *fixtureFlags) paths(namespaces []string) []string {
+	paths := make([]string, len(namespaces))
+	for i, namespace := range namespaces {
+		paths[i] = filepath.Join(f.dir, namespace)
+	}
+	return paths
+}
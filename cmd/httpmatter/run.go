@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/therewardstore/httpmatter"
+)
+
+// runTB logs to stderr in verbose mode and collects errors, e.g. failed
+// inline assertions. Fatalf panics with a fatalError, so a failing
+// request stops its group without exiting
+type runTB struct {
+	verbose bool
+	errors  []string
+}
+
+func (tb *runTB) Logf(format string, args ...any) {
+	if tb.verbose {
+		fmt.Fprintf(os.Stderr, format+"\n", args...)
+	}
+}
+
+func (tb *runTB) Errorf(format string, args ...any) {
+	tb.errors = append(tb.errors, fmt.Sprintf(format, args...))
+}
+
+func (*runTB) Fatalf(format string, args ...any) {
+	panic(fatalError{fmt.Errorf(format, args...)})
+}
+
+// runGroup is a directory and the request files to run from it, in order
+type runGroup struct {
+	dir   string
+	names []string
+}
+
+// run sends the request files in order, like an .http file in an editor,
+// and prints every response. Requests of one file or directory share a
+// session, so later requests can reference earlier ones
+func run(args []string) error {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: httpmatter run [flags] <file|dir>...")
+		flags.PrintDefaults()
+	}
+	ext := flags.String("ext", ".http", "file extension of the request files")
+	envName := flags.String("env", "", "environment, the env file <env><env-ext> next to the request files")
+	envExt := flags.String("env-ext", ".env", "env file extension")
+	timeout := flags.Duration("timeout", 30*time.Second, "timeout of each request")
+	verbose := flags.Bool("v", false, "log which fixtures are read")
+	vars := map[string]any{}
+	flags.Func("var", "variable as key=value, can be repeated", func(s string) error {
+		key, value, ok := strings.Cut(s, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("variable %q is not key=value", s)
+		}
+		vars[key] = value
+		return nil
+	})
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return fmt.Errorf("no file or directory to run")
+	}
+
+	var groups []runGroup
+	for _, arg := range flags.Args() {
+		group, err := collect(arg, *ext)
+		if err != nil {
+			return err
+		}
+		groups = append(groups, group)
+	}
+
+	client := &http.Client{Timeout: *timeout}
+	tb := &runTB{verbose: *verbose}
+	ran, failed := 0, 0
+	for _, group := range groups {
+		err := httpmatter.Init(&httpmatter.Config{
+			BaseDir:          group.dir,
+			FileExtension:    *ext,
+			EnvFileName:      *envName,
+			EnvFileExtension: *envExt,
+		})
+		if err != nil {
+			return err
+		}
+		session := httpmatter.NewSession(tb, "").WithClient(client)
+		for _, name := range group.names {
+			ran++
+			if err := runRequest(session, tb, name, vars); err != nil {
+				fmt.Printf("FAIL %s\n%s\n", filepath.Join(group.dir, name+*ext), indentLines(err.Error()))
+				failed++
+				// later requests of the group usually depend on this one
+				break
+			}
+		}
+	}
+	fmt.Printf("%d ran, %d failed\n", ran, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d requests failed", failed, ran)
+	}
+	return nil
+}
+
+// runRequest sends the named request and prints the exchange. It fails
+// when the request cannot be sent or an inline assertion fails
+func runRequest(session *httpmatter.Session, tb *runTB, name string, vars map[string]any) error {
+	reported := len(tb.errors)
+	var respm *httpmatter.ResponseMatter
+	err := catchFatal(func() {
+		respm = session.Do(name, httpmatter.WithVariables(vars))
+	})
+	if err != nil {
+		return err
+	}
+	req := session.Request(name)
+	fmt.Printf("### %s\n%s %s\n\n", name, req.Method, req.URL)
+	dump, err := httputil.DumpResponse(respm.Response, true)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s\n\n", strings.TrimRight(strings.ReplaceAll(string(dump), "\r\n", "\n"), "\n"))
+	if len(tb.errors) > reported {
+		return errors.New(strings.Join(tb.errors[reported:], "\n"))
+	}
+	return nil
+}
+
+// collect returns the request file, or the request files of the directory
+// sorted by name. Response fixtures in the directory are skipped
+func collect(path, ext string) (runGroup, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return runGroup{}, err
+	}
+	if !info.IsDir() {
+		name := filepath.Base(path)
+		if filepath.Ext(name) != ext {
+			return runGroup{}, fmt.Errorf("%s is not a %s file", path, ext)
+		}
+		return runGroup{dir: filepath.Dir(path), names: []string{strings.TrimSuffix(name, ext)}}, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return runGroup{}, err
+	}
+	group := runGroup{dir: path}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ext {
+			continue
+		}
+		isRequest, err := isRequestFile(filepath.Join(path, entry.Name()))
+		if err != nil {
+			return runGroup{}, err
+		}
+		if isRequest {
+			group.names = append(group.names, strings.TrimSuffix(entry.Name(), ext))
+		}
+	}
+	slices.Sort(group.names)
+	if len(group.names) == 0 {
+		return runGroup{}, fmt.Errorf("no request files in %s", path)
+	}
+	return group, nil
+}
+
+// requestMethods start the request lines fixtures can have
+var requestMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut,
+	http.MethodDelete, http.MethodPatch, http.MethodHead,
+}
+
+// isRequestFile reports whether the message of the file starts with
+// a request line rather than a status line. Like fixtures are read,
+// lines before the first request or status line are front matter
+func isRequestFile(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "HTTP/") {
+			return false, nil
+		}
+		if method, _, ok := strings.Cut(line, " "); ok && slices.Contains(requestMethods, method) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+func indentLines(s string) string {
+	return "    " + strings.ReplaceAll(strings.TrimRight(s, "\n"), "\n", "\n    ")
+}
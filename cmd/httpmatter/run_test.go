@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeFiles writes the files into a new temporary directory
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	return dir
+}
+
+func TestIsRequestFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		request bool
+	}{
+		{name: "request", content: "GET https://example.com/ HTTP/1.1\n", request: true},
+		{name: "front matter", content: "///\n// @name login\n///\n\nPOST https://example.com/login HTTP/1.1\n", request: true},
+		{name: "response", content: "///\n// @name login\n///\nHTTP/1.1 200 OK\n"},
+		{name: "capitalized note", content: "A note about login\nHTTP/1.1 200 OK\n"},
+		{name: "shouting note", content: "NOTE this is a response\nHTTP/1.1 200 OK\n"},
+		{name: "no message", content: "///\n// @name empty\n///\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, map[string]string{"fixture.http": tt.content})
+			request, err := isRequestFile(filepath.Join(dir, "fixture.http"))
+			require.NoError(t, err)
+			require.Equal(t, tt.request, request)
+		})
+	}
+}
+
+func TestCollect(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"02_get.http":       "GET https://example.com/users/1 HTTP/1.1\n",
+		"01_create.http":    "POST https://example.com/users HTTP/1.1\n",
+		"response_ok.http":  "HTTP/1.1 200 OK\n",
+		"notes.txt":         "GET https://example.com/ HTTP/1.1\n",
+		"03_delete.request": "DELETE https://example.com/users/1 HTTP/1.1\n",
+	})
+	empty := writeFiles(t, map[string]string{"response_ok.http": "HTTP/1.1 200 OK\n"})
+
+	tests := []struct {
+		name  string
+		path  string
+		group runGroup
+		err   string
+	}{
+		{name: "directory", path: dir, group: runGroup{dir: dir, names: []string{"01_create", "02_get"}}},
+		{name: "file", path: filepath.Join(dir, "02_get.http"), group: runGroup{dir: dir, names: []string{"02_get"}}},
+		{name: "other extension", path: filepath.Join(dir, "notes.txt"), err: "is not a .http file"},
+		{name: "no requests", path: empty, err: "no request files in"},
+		{name: "missing", path: filepath.Join(dir, "missing"), err: "no such file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			group, err := collect(tt.path, ".http")
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.group, group)
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	var calls []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+		}
+		fmt.Fprint(w, `{"id":"42","name":"Jane"}`)
+	}))
+	defer server.Close()
+
+	files := func(status int) map[string]string {
+		return map[string]string{
+			"01_create.http": fmt.Sprintf("///\n// @name create\n///\n?? status == %d\n\n"+
+				"POST {{baseURL}}/users HTTP/1.1\nContent-Type: application/json\n\n{\"name\":\"Jane\"}\n", status),
+			"02_get.http": "?? body $.name == Jane\n\nGET {{baseURL}}/users/{{create.response.body.$.id}} HTTP/1.1\n",
+		}
+	}
+
+	t.Run("passes", func(t *testing.T) {
+		calls = nil
+		dir := writeFiles(t, files(http.StatusCreated))
+		require.NoError(t, run([]string{"-var", "baseURL=" + server.URL, dir}))
+		require.Equal(t, []string{"POST /users", "GET /users/42"}, calls)
+	})
+
+	t.Run("failed assertion stops the group", func(t *testing.T) {
+		calls = nil
+		dir := writeFiles(t, files(http.StatusOK))
+		err := run([]string{"-var", "baseURL=" + server.URL, dir})
+		require.EqualError(t, err, "1 of 1 requests failed")
+		require.Equal(t, []string{"POST /users"}, calls)
+	})
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/therewardstore/httpmatter"
+)
+
+// logTB reports to the standard logger. Fatalf panics with a fatalError,
+// so a broken fixture fails a (re)load without stopping the server
+type logTB struct{}
+
+type fatalError struct{ error }
+
+func (logTB) Logf(format string, args ...any) {
+	log.Printf(format, args...)
+}
+
+func (logTB) Errorf(format string, args ...any) {
+	log.Printf("ERROR "+format, args...)
+}
+
+func (logTB) Fatalf(format string, args ...any) {
+	panic(fatalError{fmt.Errorf(format, args...)})
+}
+
+func serve(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	fixtures := fixtureFlags{}
+	fixtures.register(flags)
+	addr := flags.String("addr", ":8080", "address to listen on")
+	reload := flags.Duration("reload", time.Second, "interval to check fixtures for changes, 0 disables reloading")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	namespaces, err := fixtures.init()
+	if err != nil {
+		return err
+	}
+
+	server := &reloader{load: func() (http.Handler, error) {
+		return load(namespaces)
+	}}
+	// taken before loading, so changes made meanwhile are reloaded
+	dirs := fixtures.paths(namespaces)
+	last := snapshot(dirs)
+	if err := server.reload(); err != nil {
+		return err
+	}
+	if *reload > 0 {
+		go server.watch(dirs, last, *reload, nil)
+	}
+	log.Printf("serving %v from %s on %s", namespaces, fixtures.dir, *addr)
+	return http.ListenAndServe(*addr, server)
+}
+
+// load builds the handler, turning a fatal report into an error
+func load(namespaces []string) (handler http.Handler, err error) {
+	fatal := catchFatal(func() {
+		handler, err = httpmatter.Handler(logTB{}, namespaces...)
+	})
+	if fatal != nil {
+		return nil, fatal
+	}
+	return handler, err
+}
+
+// catchFatal runs fn and returns the error of a fatalError panic
+func catchFatal(fn func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			fatal, ok := r.(fatalError)
+			if !ok {
+				panic(r)
+			}
+			err = fatal.error
+		}
+	}()
+	fn()
+	return nil
+}
+
+// reloader serves with the last successfully loaded handler
+type reloader struct {
+	mu      sync.RWMutex
+	handler http.Handler
+	load    func() (http.Handler, error)
+}
+
+func (r *reloader) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.RLock()
+	handler := r.handler
+	r.mu.RUnlock()
+	handler.ServeHTTP(w, req)
+}
+
+func (r *reloader) reload() error {
+	handler, err := r.load()
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.handler = handler
+	r.mu.Unlock()
+	return nil
+}
+
+// watch reloads whenever a file in the directories changes from the
+// last snapshot, until stop is closed
+func (r *reloader) watch(dirs []string, last string, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+		current := snapshot(dirs)
+		if current == last {
+			continue
+		}
+		last = current
+		if err := r.reload(); err != nil {
+			log.Printf("ERROR reloading fixtures, keeping the previous ones: %v", err)
+			continue
+		}
+		log.Printf("fixtures reloaded")
+	}
+}
+
+// snapshot summarises names, sizes and modification times of all files
+func snapshot(dirs []string) string {
+	var out string
+	for _, dir := range dirs {
+		err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return err
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			out += fmt.Sprintf("%s %d %d\n", path, info.Size(), info.ModTime().UnixNano())
+			return nil
+		})
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("ERROR watching %s: %v", dir, err)
+		}
+	}
+	return out
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeReload(t *testing.T) {
+	request := "GET https://example.com/users HTTP/1.1\n"
+	response := func(name string) string {
+		return "HTTP/1.1 200 OK\nContent-Type: text/plain\n\n" + name
+	}
+
+	tests := []struct {
+		name     string
+		response string
+		body     string
+	}{
+		{name: "changed fixture is served", response: response("John"), body: "John"},
+		{name: "fatal fixture error keeps the previous fixtures", response: "///\n// @error dns\n///\n", body: "Jane"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			must := require.New(t)
+			dir := writeFiles(t, map[string]string{
+				"request_users.http":  request,
+				"response_users.http": response("Jane"),
+			})
+			fixtures := fixtureFlags{dir: filepath.Dir(dir), namespaces: filepath.Base(dir), ext: ".http", envExt: ".env"}
+			namespaces, err := fixtures.init()
+			must.NoError(err)
+
+			server := &reloader{load: func() (http.Handler, error) {
+				return load(namespaces)
+			}}
+			dirs := fixtures.paths(namespaces)
+			last := snapshot(dirs)
+			must.NoError(server.reload())
+			stop, stopped := make(chan struct{}), make(chan struct{})
+			go func() {
+				server.watch(dirs, last, 10*time.Millisecond, stop)
+				close(stopped)
+			}()
+			defer func() {
+				close(stop)
+				<-stopped
+			}()
+
+			get := func() string {
+				rec := httptest.NewRecorder()
+				server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users", nil))
+				body, _ := io.ReadAll(rec.Body)
+				return string(body)
+			}
+			must.Equal("Jane", get())
+
+			must.NoError(os.WriteFile(filepath.Join(dir, "response_users.http"), []byte(tt.response), 0644))
+			// give the watcher a few ticks to reload, or to fail reloading
+			time.Sleep(100 * time.Millisecond)
+			must.Equal(tt.body, get())
+		})
+	}
+}
+
+func TestLoadCatchesFatal(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"request_users.http":  "GET https://example.com/users HTTP/1.1\n",
+		"response_users.http": "///\n// @error dns\n///\n",
+	})
+	fixtures := fixtureFlags{dir: filepath.Dir(dir), namespaces: filepath.Base(dir), ext: ".http", envExt: ".env"}
+	namespaces, err := fixtures.init()
+	require.NoError(t, err)
+	_, err = load(namespaces)
+	require.ErrorContains(t, err, `unknown error "dns"`)
+}
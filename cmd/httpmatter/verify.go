@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/therewardstore/httpmatter"
+)
+
+// verify runs the request fixtures against a provider and compares the
+// answers with the paired response fixtures
+func verify(args []string) error {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	fixtures := fixtureFlags{}
+	fixtures.register(flags)
+	baseURL := flags.String("base-url", "", "base URL of the provider (default the URL of each request fixture)")
+	timeout := flags.Duration("timeout", 30*time.Second, "timeout of each request")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	namespaces, err := fixtures.init()
+	if err != nil {
+		return err
+	}
+
+	results, err := httpmatter.NewContract(namespaces...).
+		BaseURL(*baseURL).
+		Client(&http.Client{Timeout: *timeout}).
+		Run()
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, result := range results {
+		fmt.Println(result)
+		if !result.Passed() {
+			failed++
+		}
+	}
+	fmt.Printf("%d passed, %d failed\n", len(results)-failed, failed)
+	if failed > 0 {
+		return fmt.Errorf("contract failed for %d of %d pairs", failed, len(results))
+	}
+	return nil
+}
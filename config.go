@@ -11,6 +11,17 @@ type Config struct {
 	EnvFileExtension  string
 	DisableLogs       bool
 	TemplateConverter func(content string) string
+	// Sanitizers run on every matter before it is saved
+	Sanitizers []Sanitizer
+	// ReverseVars puts back {{name}} placeholders on every Save,
+	// see WithReverseVars
+	ReverseVars bool
+	// Canonical writes every saved matter in a stable, diff friendly form,
+	// see WithCanonical
+	Canonical bool
+	// DropHeaders are left out by the canonical writer,
+	// DefaultDropHeaders when empty
+	DropHeaders []string
 }
 
 func (c *Config) copy() Config {
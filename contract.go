@@ -0,0 +1,173 @@
+package httpmatter
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ContractResult is the outcome of one request/response fixture pair
+type ContractResult struct {
+	Namespace string
+	Request   string
+	Response  string
+	// Diff is empty when the provider answered as the response fixture says
+	Diff Diff
+	// Err is set when the pair could not be run, e.g. a broken fixture
+	// or a provider which cannot be reached
+	Err error
+}
+
+// Passed reports whether the provider answered as the response fixture says
+func (r ContractResult) Passed() bool {
+	return r.Err == nil && r.Diff.Empty()
+}
+
+// String returns a one line summary, followed by the diff or error on failure
+func (r ContractResult) String() string {
+	pair := fmt.Sprintf("%s/%s -> %s", r.Namespace, r.Request, r.Response)
+	switch {
+	case r.Err != nil:
+		return "FAIL " + pair + "\n" + indent(r.Err.Error()+"\n")
+	case !r.Diff.Empty():
+		return "FAIL " + pair + "\n" + indent(r.Diff.String())
+	}
+	return "PASS " + pair
+}
+
+func indent(s string) string {
+	lines := strings.SplitAfter(strings.TrimRight(s, "\n"), "\n")
+	return "    " + strings.Join(lines, "    ")
+}
+
+// Contract runs the request fixtures of the routes (see Routes) against
+// a provider and compares every answer with its paired response fixture,
+// so a provider can check in its CI what its consumers expect from it.
+// A route with several responses is checked against its first response,
+// the one the mocks answer with
+type Contract struct {
+	namespaces []string
+	baseURL    string
+	handler    http.Handler
+	client     *http.Client
+	opts       []Option
+}
+
+func NewContract(namespaces ...string) *Contract {
+	return &Contract{
+		namespaces: namespaces,
+		client:     http.DefaultClient,
+	}
+}
+
+// BaseURL sends the requests to the provider at the base URL instead of
+// the host of the request fixtures, the path of the base URL is prepended
+func (c *Contract) BaseURL(baseURL string) *Contract {
+	c.baseURL = baseURL
+	return c
+}
+
+// Handler serves the requests with the handler, without a network round trip
+func (c *Contract) Handler(handler http.Handler) *Contract {
+	c.handler = handler
+	return c
+}
+
+// Client sets the client sending the requests, http.DefaultClient by default
+func (c *Contract) Client(client *http.Client) *Contract {
+	c.client = client
+	return c
+}
+
+// Options are used to create every request and response matter,
+// e.g. WithVariables for the variables of the fixtures
+func (c *Contract) Options(opts ...Option) *Contract {
+	c.opts = append(c.opts, opts...)
+	return c
+}
+
+// Run runs every pair of every namespace in order and returns the results.
+// The error is only set when the base URL is invalid or the routes of
+// a namespace cannot be read
+func (c *Contract) Run() ([]ContractResult, error) {
+	var base *url.URL
+	if c.baseURL != "" {
+		u, err := url.Parse(c.baseURL)
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("base url %q needs a scheme and a host", c.baseURL)
+		}
+		base = u
+	}
+	var results []ContractResult
+	for _, namespace := range c.namespaces {
+		routes, err := Routes(namespace)
+		if err != nil {
+			return results, err
+		}
+		for _, route := range routes {
+			results = append(results, c.run(base, route.Namespace, route.Request, route.Responses[0]))
+		}
+	}
+	return results, nil
+}
+
+// Verify runs the contract and fails the test for every pair which fails
+func (c *Contract) Verify(t TB) []ContractResult {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+	results, err := c.Run()
+	if err != nil {
+		t.Fatalf("error running contract: %v", err)
+	}
+	for _, result := range results {
+		if result.Passed() {
+			t.Logf("%s", result)
+		} else {
+			t.Errorf("%s", result)
+		}
+	}
+	return results
+}
+
+func (c *Contract) run(base *url.URL, namespace, reqname, respname string) ContractResult {
+	result := ContractResult{Namespace: namespace, Request: reqname, Response: respname}
+	req, err := Request(namespace, reqname, c.opts...)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	expected, err := Response(namespace, respname, c.opts...)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	resp, err := c.send(base, req.Request)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer resp.Body.Close()
+	result.Diff, result.Err = expected.Match(resp)
+	return result
+}
+
+// send sends the request to the handler, the base URL or
+// the URL of the fixture, in that order
+func (c *Contract) send(base *url.URL, req *http.Request) (*http.Response, error) {
+	if c.handler != nil {
+		return serveRequest(c.handler, req), nil
+	}
+	if base != nil {
+		req.URL.Scheme = base.Scheme
+		req.URL.Host = base.Host
+		req.URL.Path = strings.TrimSuffix(base.Path, "/") + orRoot(req.URL.Path)
+		req.URL.RawPath = ""
+		req.Host = base.Host
+	}
+	return c.client.Do(req)
+}
@@ -0,0 +1,75 @@
+package httpmatter
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// providerHandler is the provider side of the contract fixtures,
+// broken answers the user with a wrong field
+func providerHandler(broken bool) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("POST /users", usersHandler(http.StatusCreated, ""))
+	mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		field := "name"
+		if broken {
+			field = "full_name"
+		}
+		fmt.Fprintf(w, `{"id":%q,%q:"Jane"}`, r.PathValue("id"), field)
+	})
+	return mux
+}
+
+func TestContractHandler(t *testing.T) {
+	must := require.New(t)
+	results := NewContract("contract").Handler(providerHandler(false)).Verify(t)
+	must.Len(results, 2)
+	for _, result := range results {
+		must.True(result.Passed(), result.String())
+	}
+	must.Equal("PASS contract/request_create_user -> response_create_user", results[0].String())
+}
+
+func TestContractBaseURL(t *testing.T) {
+	must := require.New(t)
+	server := httptest.NewServer(http.StripPrefix("/api", providerHandler(true)))
+	defer server.Close()
+
+	tb := &recordingTB{}
+	results := NewContract("contract").BaseURL(server.URL + "/api/").Verify(tb)
+	must.Len(results, 2)
+	must.True(results[0].Passed(), results[0].String())
+	must.False(results[1].Passed())
+	must.Len(tb.errors, 1)
+	must.True(strings.HasPrefix(tb.errors[0], "FAIL contract/request_get_user -> response_get_user\n    body differs:\n"), tb.errors[0])
+	must.Contains(tb.errors[0], `    +   "full_name": "Jane",`)
+
+	_, err := NewContract("contract").BaseURL("localhost:8080").Run()
+	must.Error(err)
+}
+
+func TestContractUnreachable(t *testing.T) {
+	must := require.New(t)
+	server := httptest.NewServer(providerHandler(false))
+	server.Close()
+
+	results, err := NewContract("contract").BaseURL(server.URL).Run()
+	must.NoError(err)
+	must.Len(results, 2)
+	must.Error(results[0].Err)
+	must.False(results[0].Passed())
+}
+
+func TestContractUsesFirstResponse(t *testing.T) {
+	must := require.New(t)
+	results, err := NewContract("routes").Handler(http.NotFoundHandler()).Run()
+	must.NoError(err)
+	must.Len(results, 2)
+	must.Equal("response_order_games", results[1].Response)
+}
@@ -12,6 +12,10 @@ var ErrParsingTemplate = newErrFn("failed to parse template")
 var ErrExecutingTemplate = newErrFn("failed to execute template")
 var ErrCreatingMatter = newErrFn("failed to create matter")
 var ErrNotImplemented = newErrFn("not implemented")
+var ErrNoTrip = newErrFn("no trip matches")
+var ErrSanitizing = newErrFn("failed to sanitize matter")
+var ErrUpdatingFixture = newErrFn("failed to update fixture")
+var ErrAssertion = newErrFn("assertion failed")
 
 type err struct {
 	message string
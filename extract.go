@@ -0,0 +1,303 @@
+package httpmatter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+)
+
+// peekBody reads the whole body and puts a replayBody back in place,
+// so the body can still be read (or sent) after inspection. A replayBody
+// is not read again, it returns its bytes even when it was read already
+func peekBody(body *io.ReadCloser) ([]byte, error) {
+	if body == nil || *body == nil {
+		return nil, nil
+	}
+	if replay, ok := (*body).(*replayBody); ok {
+		return replay.data, nil
+	}
+	b, err := io.ReadAll(*body)
+	if err != nil {
+		return nil, err
+	}
+	_ = (*body).Close()
+	*body = newReplayBody(b)
+	return b, nil
+}
+
+// replayBody is a body reader which keeps its bytes,
+// so they can be inspected or served again after it was read
+type replayBody struct {
+	*bytes.Reader
+	data []byte
+}
+
+func newReplayBody(data []byte) *replayBody {
+	return &replayBody{Reader: bytes.NewReader(data), data: data}
+}
+
+func (*replayBody) Close() error {
+	return nil
+}
+
+// queryBody picks a value out of a body, JSONPath expressions start with $
+// and XPath expressions start with /, an empty expression returns the body
+func queryBody(body []byte, expr string) (any, error) {
+	switch {
+	case expr == "":
+		return string(body), nil
+	case strings.HasPrefix(expr, "$"):
+		var v any
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		if err := decoder.Decode(&v); err != nil {
+			return nil, err
+		}
+		return jsonPath(v, expr)
+	case strings.HasPrefix(expr, "/"):
+		return xPath(body, expr)
+	}
+	return nil, fmt.Errorf("unsupported body query %q", expr)
+}
+
+// xPath returns the inner text of the first node matching expr
+func xPath(body []byte, expr string) (string, error) {
+	doc, err := xmlquery.Parse(bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	node, err := xmlquery.Query(doc, expr)
+	if err != nil {
+		return "", err
+	}
+	if node == nil {
+		return "", fmt.Errorf("no node found for %q", expr)
+	}
+	return node.InnerText(), nil
+}
+
+// jsonPath evaluates a small JSONPath subset against a decoded JSON value.
+// Supported are $, .key, ['key'], [index] and [*]. Paths without the $
+// are read gjson style, like data.items.0.id or data.items.#.id
+func jsonPath(v any, path string) (any, error) {
+	expr := strings.TrimSpace(path)
+	if !strings.HasPrefix(expr, "$") {
+		expr = "$." + expr
+	}
+	steps, err := splitJSONPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	for i, step := range steps {
+		if step == "#" {
+			steps[i] = "*"
+		}
+	}
+	return walkJSON(v, steps, path)
+}
+
+func walkJSON(v any, steps []string, path string) (any, error) {
+	if len(steps) == 0 {
+		return v, nil
+	}
+	step, rest := steps[0], steps[1:]
+	switch node := v.(type) {
+	case map[string]any:
+		if step == "*" {
+			// sorted, so the values come in the same order every time
+			out := make([]any, 0, len(node))
+			for _, key := range slices.Sorted(maps.Keys(node)) {
+				found, err := walkJSON(node[key], rest, path)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, found)
+			}
+			return out, nil
+		}
+		child, ok := node[step]
+		if !ok {
+			return nil, fmt.Errorf("%q: key %q not found", path, step)
+		}
+		return walkJSON(child, rest, path)
+	case []any:
+		if step == "*" {
+			out := make([]any, 0, len(node))
+			for _, child := range node {
+				found, err := walkJSON(child, rest, path)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, found)
+			}
+			return out, nil
+		}
+		index, err := strconv.Atoi(step)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %q is not an array index", path, step)
+		}
+		if index < 0 {
+			index += len(node)
+		}
+		if index < 0 || index >= len(node) {
+			return nil, fmt.Errorf("%q: index %d out of range", path, index)
+		}
+		return walkJSON(node[index], rest, path)
+	}
+	return nil, fmt.Errorf("%q: cannot select %q from %T", path, step, v)
+}
+
+// splitJSONPath turns $.a['b'][0] into [a b 0]
+func splitJSONPath(path string) ([]string, error) {
+	path = strings.TrimPrefix(strings.TrimSpace(path), "$")
+	var steps []string
+	for len(path) > 0 {
+		switch path[0] {
+		case '.':
+			path = path[1:]
+			end := strings.IndexAny(path, ".[")
+			if end == -1 {
+				end = len(path)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("empty key in json path")
+			}
+			steps = append(steps, path[:end])
+			path = path[end:]
+		case '[':
+			end := strings.Index(path, "]")
+			if end == -1 {
+				return nil, fmt.Errorf("unterminated [ in json path")
+			}
+			steps = append(steps, strings.Trim(path[1:end], `'"`))
+			path = path[end+1:]
+		default:
+			return nil, fmt.Errorf("unexpected %q in json path", path[0])
+		}
+	}
+	return steps, nil
+}
+
+// BodyReader is a matter with a body, a RequestMatter or a ResponseMatter
+type BodyReader interface {
+	BodyBytes() ([]byte, error)
+}
+
+// DecodeJSON decodes the JSON body of the matter into a T,
+// the body stays readable
+func DecodeJSON[T any](m BodyReader) (T, error) {
+	var v T
+	body, err := m.BodyBytes()
+	if err != nil {
+		return v, err
+	}
+	err = json.Unmarshal(body, &v)
+	return v, err
+}
+
+// JSONPathAs picks the value at path out of the JSON body of the matter
+// and decodes it into a T, e.g. JSONPathAs[int](resp, "$.data.id")
+func JSONPathAs[T any](m BodyReader, path string) (T, error) {
+	var v T
+	found, err := bodyJSONPath(m, path)
+	if err != nil {
+		return v, err
+	}
+	b, err := json.Marshal(found)
+	if err != nil {
+		return v, err
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return v, fmt.Errorf("%q: %w", path, err)
+	}
+	return v, nil
+}
+
+// JSONPath returns the value at path in the JSON body, numbers are
+// json.Number. Paths are JSONPath ($.data.items[0].id) or gjson style
+// (data.items.0.id)
+func (rm *RequestMatter) JSONPath(path string) (any, error) {
+	return bodyJSONPath(rm, path)
+}
+
+// XPath returns the inner text of the first node of the XML body
+// matching expr
+func (rm *RequestMatter) XPath(expr string) (string, error) {
+	return bodyXPath(rm, expr)
+}
+
+// FormField returns the first value of the field of the form body
+func (rm *RequestMatter) FormField(name string) (string, error) {
+	return bodyFormField(rm, name)
+}
+
+// QueryParam returns the first value of the query parameter of the URL
+func (rm *RequestMatter) QueryParam(name string) (string, error) {
+	return lookupValue(rm.URL.Query(), "query parameter", name)
+}
+
+// JSONPath returns the value at path in the JSON body, see RequestMatter.JSONPath
+func (rm *ResponseMatter) JSONPath(path string) (any, error) {
+	return bodyJSONPath(rm, path)
+}
+
+// XPath returns the inner text of the first node of the XML body
+// matching expr
+func (rm *ResponseMatter) XPath(expr string) (string, error) {
+	return bodyXPath(rm, expr)
+}
+
+// FormField returns the first value of the field of the form body
+func (rm *ResponseMatter) FormField(name string) (string, error) {
+	return bodyFormField(rm, name)
+}
+
+func bodyJSONPath(m BodyReader, path string) (any, error) {
+	body, err := m.BodyBytes()
+	if err != nil {
+		return nil, err
+	}
+	var v any
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	return jsonPath(v, path)
+}
+
+func bodyXPath(m BodyReader, expr string) (string, error) {
+	body, err := m.BodyBytes()
+	if err != nil {
+		return "", err
+	}
+	return xPath(body, expr)
+}
+
+func bodyFormField(m BodyReader, name string) (string, error) {
+	body, err := m.BodyBytes()
+	if err != nil {
+		return "", err
+	}
+	values, err := url.ParseQuery(string(bytes.TrimSpace(body)))
+	if err != nil {
+		return "", err
+	}
+	return lookupValue(values, "form field", name)
+}
+
+// lookupValue returns the first value of the key, an error when it is missing
+func lookupValue(values url.Values, kind, name string) (string, error) {
+	if !values.Has(name) {
+		return "", fmt.Errorf("%s %q not found", kind, name)
+	}
+	return values.Get(name), nil
+}
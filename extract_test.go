@@ -0,0 +1,132 @@
+package httpmatter
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryBodyJSONPath(t *testing.T) {
+	must := require.New(t)
+	body := []byte(`{"data":{"items":[{"id":1},{"id":2}],"odd key":"x"}}`)
+
+	v, err := queryBody(body, "$.data.items[1].id")
+	must.NoError(err)
+	must.Equal(json.Number("2"), v)
+
+	v, err = queryBody(body, "$.data['odd key']")
+	must.NoError(err)
+	must.Equal("x", v)
+
+	v, err = queryBody(body, "$.data.items[*].id")
+	must.NoError(err)
+	must.Equal([]any{json.Number("1"), json.Number("2")}, v)
+
+	for range 10 {
+		v, err = queryBody([]byte(`{"c":3,"a":1,"d":4,"b":2}`), "$[*]")
+		must.NoError(err)
+		must.Equal([]any{json.Number("1"), json.Number("2"), json.Number("3"), json.Number("4")}, v)
+	}
+
+	_, err = queryBody(body, "$.data.missing")
+	must.Error(err)
+}
+
+func TestQueryBodyXPath(t *testing.T) {
+	must := require.New(t)
+	body := []byte(`<order id="42"><item>book</item></order>`)
+
+	v, err := queryBody(body, "//order/item")
+	must.NoError(err)
+	must.Equal("book", v)
+
+	v, err = queryBody(body, "/order/@id")
+	must.NoError(err)
+	must.Equal("42", v)
+}
+
+func TestResponseMatterExtraction(t *testing.T) {
+	must := require.New(t)
+	resp := &ResponseMatter{Response: &http.Response{
+		Body: io.NopCloser(strings.NewReader(`{"data":{"items":[{"id":1,"name":"a"},{"id":2,"name":"b"}]}}`)),
+	}}
+
+	v, err := resp.JSONPath("$.data.items[0].id")
+	must.NoError(err)
+	must.Equal(json.Number("1"), v)
+
+	v, err = resp.JSONPath("data.items.1.name")
+	must.NoError(err)
+	must.Equal("b", v)
+
+	ids, err := JSONPathAs[[]int](resp, "data.items.#.id")
+	must.NoError(err)
+	must.Equal([]int{1, 2}, ids)
+
+	_, err = JSONPathAs[int](resp, "$.data.items[0].name")
+	must.ErrorContains(err, `"$.data.items[0].name"`)
+
+	type item struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	decoded, err := DecodeJSON[struct {
+		Data struct{ Items []item }
+	}](resp)
+	must.NoError(err)
+	must.Equal([]item{{1, "a"}, {2, "b"}}, decoded.Data.Items)
+
+	body, err := io.ReadAll(resp.Body)
+	must.NoError(err)
+	must.Contains(string(body), `"items"`, "body must stay readable")
+}
+
+func TestResponseMatterXPathAndForm(t *testing.T) {
+	must := require.New(t)
+	xml := &ResponseMatter{Response: &http.Response{
+		Body: io.NopCloser(strings.NewReader(`<order id="42"><item>book</item></order>`)),
+	}}
+	v, err := xml.XPath("/order/@id")
+	must.NoError(err)
+	must.Equal("42", v)
+	v, err = xml.XPath("//item")
+	must.NoError(err)
+	must.Equal("book", v)
+
+	form := &ResponseMatter{Response: &http.Response{
+		Body: io.NopCloser(strings.NewReader("access_token=abc&expires_in=3600\n")),
+	}}
+	v, err = form.FormField("access_token")
+	must.NoError(err)
+	must.Equal("abc", v)
+	_, err = form.FormField("refresh_token")
+	must.EqualError(err, `form field "refresh_token" not found`)
+}
+
+func TestRequestMatterExtraction(t *testing.T) {
+	must := require.New(t)
+	req := &RequestMatter{Request: httptest.NewRequest(http.MethodPost,
+		"https://example.com/login?next=%2Fhome&empty=", strings.NewReader("user=jane&scope=a+b"))}
+
+	v, err := req.FormField("scope")
+	must.NoError(err)
+	must.Equal("a b", v)
+
+	v, err = req.QueryParam("next")
+	must.NoError(err)
+	must.Equal("/home", v)
+	v, err = req.QueryParam("empty")
+	must.NoError(err)
+	must.Equal("", v)
+	_, err = req.QueryParam("missing")
+	must.EqualError(err, `query parameter "missing" not found`)
+
+	body, err := req.BodyString()
+	must.NoError(err)
+	must.Equal("user=jane&scope=a+b", body, "body must stay readable")
+}
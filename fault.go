@@ -0,0 +1,138 @@
+package httpmatter
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+// Network errors a response fixture can fail with through the
+// "@error" directive, e.g. "// @error connection-refused"
+const (
+	NetworkConnectionRefused = "connection-refused"
+	NetworkReset             = "reset"
+	NetworkTimeout           = "timeout"
+	NetworkTLS               = "tls"
+)
+
+// NetworkError returns an error like the one a real transport returns for
+// the kind, e.g. errors.Is(NetworkError(NetworkReset), syscall.ECONNRESET).
+// Unknown kinds return nil
+func NetworkError(kind string) error {
+	switch kind {
+	case NetworkConnectionRefused:
+		return &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	case NetworkReset:
+		return &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+	case NetworkTimeout:
+		return &net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}
+	case NetworkTLS:
+		return &tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}
+	}
+	return nil
+}
+
+// ErrorResponse returns a response which fails the request with err
+// instead of answering it, so a responder can inject transport errors:
+//
+//	func(req *http.Request, reqm *RequestMatter, respms []*ResponseMatter) *ResponseMatter {
+//		if attempts++; attempts < 3 {
+//			return httpmatter.ErrorResponse(httpmatter.NetworkError(httpmatter.NetworkReset))
+//		}
+//		return respms[0]
+//	}
+//
+// Timeouts wait for the deadline of the request context, see Fail.
+// err must not be nil, a nil err fails the request with an error saying so
+func ErrorResponse(err error) *ResponseMatter {
+	if err == nil {
+		err = errors.New("ErrorResponse called without an error")
+	}
+	rm := NewResponseMatter("", "error")
+	rm.Name = "error " + err.Error()
+	rm.fault = fault{err: err}
+	return rm
+}
+
+// fault is a transport failure or delay of a response
+type fault struct {
+	delay time.Duration
+	err   error
+}
+
+// readFault reads the "@error" and "@delay" directives
+func (m *Matter) readFault() (fault, error) {
+	f := fault{}
+	if kind, ok := m.Directive("error"); ok {
+		if f.err = NetworkError(kind); f.err == nil {
+			return f, fmt.Errorf("unknown error %q, use %s, %s, %s or %s", kind,
+				NetworkConnectionRefused, NetworkReset, NetworkTimeout, NetworkTLS)
+		}
+	}
+	if delay, ok := m.Directive("delay"); ok {
+		d, err := time.ParseDuration(delay)
+		if err != nil {
+			return f, fmt.Errorf("invalid delay %q: %w", delay, err)
+		}
+		f.delay = d
+	}
+	return f, nil
+}
+
+// failing reports whether the response fails instead of answering
+func (f fault) failing() bool {
+	return f.err != nil
+}
+
+// wait waits for the delay, then returns the error. A timeout waits for
+// the deadline of the context and returns its error, it fails right away
+// when the context has no deadline, like the ones of server requests which
+// only end when the client goes away. Delays end early with the context
+func (f fault) wait(ctx context.Context) error {
+	if err := f.waitErr(ctx); err != nil {
+		return injectedError{err}
+	}
+	return nil
+}
+
+func (f fault) waitErr(ctx context.Context) error {
+	if f.delay > 0 {
+		timer := time.NewTimer(f.delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if _, ok := ctx.Deadline(); ok && f.err != nil && isTimeout(f.err) {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return f.err
+}
+
+// injectedError marks an error of a fault, so server mode drops the
+// connection instead of answering 404
+type injectedError struct{ error }
+
+func (e injectedError) Unwrap() error {
+	return e.error
+}
+
+// Timeout lets url.Error report injected timeouts as timeouts
+func (e injectedError) Timeout() bool {
+	return isTimeout(e.error)
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) ||
+		errors.As(err, &netErr) && netErr.Timeout()
+}
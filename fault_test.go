@@ -0,0 +1,197 @@
+package httpmatter
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func postBooks(client *http.Client) (*http.Response, error) {
+	return postBooksContext(context.Background(), client)
+}
+
+func postBooksContext(ctx context.Context, client *http.Client) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://example.com/api/order",
+		strings.NewReader(`{"product":"books","quantity":1}`))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return client.Do(req)
+}
+
+func TestFaultDirectives(t *testing.T) {
+	t.Run("connection refused", func(t *testing.T) {
+		must := require.New(t)
+		h := NewHTTP(t, "matching", "fault").
+			Add("request_order_books", "response_refused").
+			Respond(nil)
+		h.Init()
+		defer h.Destroy()
+		_, err := postBooks(h.Client())
+		must.ErrorIs(err, syscall.ECONNREFUSED)
+	})
+
+	t.Run("timeout waits for the deadline", func(t *testing.T) {
+		must := require.New(t)
+		h := NewHTTP(t, "matching", "fault").
+			Add("request_order_books", "response_timeout").
+			Respond(nil)
+		h.Init()
+		defer h.Destroy()
+		client := h.Client()
+		client.Timeout = 30 * time.Millisecond
+		start := time.Now()
+		_, err := postBooks(client)
+		must.GreaterOrEqual(time.Since(start), 30*time.Millisecond)
+		var urlErr *url.Error
+		must.ErrorAs(err, &urlErr)
+		must.True(urlErr.Timeout(), err.Error())
+	})
+
+	t.Run("timeout without deadline", func(t *testing.T) {
+		must := require.New(t)
+		h := NewHTTP(t, "matching", "fault").
+			Add("request_order_books", "response_timeout").
+			Respond(nil)
+		h.Init()
+		defer h.Destroy()
+		_, err := postBooks(h.Client())
+		var urlErr *url.Error
+		must.ErrorAs(err, &urlErr)
+		must.True(urlErr.Timeout(), err.Error())
+	})
+
+	t.Run("delay", func(t *testing.T) {
+		must := require.New(t)
+		h := NewHTTP(t, "matching", "fault").
+			Add("request_order_books", "response_slow").Times(2).
+			Respond(nil)
+		h.Init()
+		defer h.Destroy()
+
+		start := time.Now()
+		resp, err := postBooks(h.Client())
+		must.NoError(err)
+		body, err := io.ReadAll(resp.Body)
+		must.NoError(err)
+		must.JSONEq(`{"ok": true}`, string(body))
+		must.GreaterOrEqual(time.Since(start), 50*time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err = postBooksContext(ctx, h.Client())
+		must.ErrorIs(err, context.DeadlineExceeded)
+	})
+
+	t.Run("unknown error", func(t *testing.T) {
+		must := require.New(t)
+		tb := &recordingTB{}
+		h := NewHTTP(tb, "matching", "fault").
+			Add("request_order_books", "response_unknown").
+			Respond(nil)
+		h.Init()
+		must.NotEmpty(tb.errors)
+		must.Contains(tb.errors[0], `unknown error "dns"`)
+	})
+}
+
+func TestFail(t *testing.T) {
+	must := require.New(t)
+	h := NewHTTP(t, "matching").
+		Add("request_order_books").Times(2).
+		Fail(NetworkError(NetworkReset)).
+		Add("request_order_books", "response_order_books").
+		Respond(nil)
+	h.Init()
+	defer h.Destroy()
+
+	var attempts int
+	var resp *http.Response
+	var err error
+	for attempts = 1; attempts <= 3; attempts++ {
+		if resp, err = postBooks(h.Client()); err == nil {
+			break
+		}
+		must.ErrorIs(err, syscall.ECONNRESET)
+	}
+	must.NoError(err)
+	must.Equal(3, attempts)
+	must.Equal(http.StatusCreated, resp.StatusCode)
+}
+
+func TestErrorResponse(t *testing.T) {
+	must := require.New(t)
+	calls := 0
+	h := NewHTTP(t, "matching").
+		Add("request_order_books", "response_order_books").Times(2).
+		Respond(func(req *http.Request, reqm *RequestMatter, respms []*ResponseMatter) *ResponseMatter {
+			if calls++; calls == 1 {
+				return ErrorResponse(NetworkError(NetworkTLS))
+			}
+			return respms[0]
+		})
+	h.Init()
+	defer h.Destroy()
+
+	_, err := postBooks(h.Client())
+	var tlsErr *tls.CertificateVerificationError
+	must.ErrorAs(err, &tlsErr)
+	must.EqualError(ErrorResponse(nil).fault.err, "ErrorResponse called without an error")
+	resp, err := postBooks(h.Client())
+	must.NoError(err)
+	must.Equal(http.StatusCreated, resp.StatusCode)
+}
+
+func TestFaultServer(t *testing.T) {
+	must := require.New(t)
+	h := NewHTTP(t, "matching", "fault").
+		Add("request_order_books", "response_reset").
+		Respond(nil).
+		Add("request_order_books", "response_order_books").
+		Respond(nil)
+	h.StartServer()
+	h.Init()
+	defer h.Destroy()
+
+	resp, err := http.Post(h.URL()+"/api/order", "application/json",
+		strings.NewReader(`{"product":"books","quantity":1}`))
+	must.Error(err)
+	must.Nil(resp)
+	must.False(errors.Is(err, context.DeadlineExceeded))
+
+	resp, err = http.Post(h.URL()+"/api/order", "application/json",
+		strings.NewReader(`{"product":"books","quantity":1}`))
+	must.NoError(err)
+	defer resp.Body.Close()
+	must.Equal(http.StatusCreated, resp.StatusCode)
+}
+
+func TestFaultServerTimeout(t *testing.T) {
+	must := require.New(t)
+	h := NewHTTP(t, "matching", "fault").
+		Add("request_order_books", "response_timeout").
+		Respond(nil)
+	h.StartServer()
+	h.Init()
+
+	// the server cannot see the deadline of the client, it drops the
+	// connection right away instead of waiting for the client to give up
+	client := &http.Client{Timeout: 10 * time.Second}
+	start := time.Now()
+	resp, err := client.Post(h.URL()+"/api/order", "application/json",
+		strings.NewReader(`{"product":"books","quantity":1}`))
+	must.Error(err)
+	must.Nil(resp)
+	must.Less(time.Since(start), 5*time.Second)
+	h.Destroy()
+}
@@ -1,8 +1,8 @@
 package httpmatter
 
 import (
-	"bufio"
 	"bytes"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -22,28 +22,34 @@ func openFile(filepath string) (*os.File, error) {
 	return file, nil
 }
 
-// readFile reads a file and returns the frontmatter and content
+// readFile reads a file and returns the frontmatter and content.
+// The content starts at the first request or status line and is kept
+// byte for byte, so bodies round trip as they were saved
 func readFile(filepath string) (string, string, error) {
 	file, err := openFile(filepath)
 	if err != nil {
 		return "", "", err
 	}
 	defer file.Close()
-	sections := []*bytes.Buffer{
-		bytes.NewBufferString(""),
-		bytes.NewBufferString(""),
+	b, err := io.ReadAll(file)
+	if err != nil {
+		return "", "", err
 	}
-	index := 0
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
+	for offset := 0; offset < len(b); {
+		end := bytes.IndexByte(b[offset:], '\n')
+		next := offset + end + 1
+		if end == -1 {
+			next = len(b)
+			end = len(b) - offset
+		}
+		line := strings.TrimSuffix(string(b[offset:offset+end]), "\r")
 		if isContentLine(line) {
-			index = 1
+			return string(b[:offset]), string(b[offset:]), nil
 		}
-		sections[index].WriteString(line + "\n")
+		offset = next
 	}
-	return sections[0].String(), sections[1].String(), nil
+	return string(b), "", nil
 }
 
 func isContentLine(line string) bool {
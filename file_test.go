@@ -1,6 +1,8 @@
 package httpmatter
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -20,3 +22,14 @@ func TestIsContentLine(t *testing.T) {
 	should.False(isContentLine("# This is a comment"))
 	should.False(isContentLine(""))
 }
+
+func TestReadFileKeepsContentBytes(t *testing.T) {
+	should := assert.New(t)
+	path := filepath.Join(t.TempDir(), "fixture.http")
+	should.NoError(os.WriteFile(path, []byte("///\r\n// @name x\r\n///\r\nHTTP/1.1 200 OK\r\n\r\nno newline"), 0644))
+
+	front, content, err := readFile(path)
+	should.NoError(err)
+	should.Equal("///\r\n// @name x\r\n///\r\n", front)
+	should.Equal("HTTP/1.1 200 OK\r\n\r\nno newline", content)
+}
@@ -3,12 +3,17 @@ module github.com/therewardstore/httpmatter
 go 1.24.1
 
 require (
+	github.com/antchfx/xmlquery v1.4.4
 	github.com/jarcoal/httpmock v1.4.0
 	github.com/stretchr/testify v1.11.1
 )
 
 require (
+	github.com/antchfx/xpath v1.3.3 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/golang/groupcache v0.0.0-20210331224755-41bb18bfe9da // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/net v0.33.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
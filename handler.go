@@ -0,0 +1,80 @@
+package httpmatter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+// ServeFixture sends the request fixture through the handler and compares
+// what it writes with the response fixture, see ResponseMatter.Match.
+// The response fixture is rendered with the request, so it can use
+// {{request.<path>}}. Headers which change on every call can be skipped
+// with a front matter directive like "// @ignore-headers Date, X-Request-Id".
+// Differences fail the test with a diff, as do failed inline assertions
+// ("?? status == 201") of either fixture. The recorded response is
+// returned for further checks
+func ServeFixture(t TB, handler http.Handler, namespace, reqname, respname string, opts ...Option) *http.Response {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+	opts = append([]Option{WithTB(t)}, opts...)
+	req, err := Request(namespace, reqname, opts...)
+	if err != nil {
+		t.Fatalf("error creating matter for %s: %v", reqname, err)
+		return nil
+	}
+	expected, err := Response(namespace, respname, opts...)
+	if err != nil {
+		t.Fatalf("error creating matter for %s: %v", respname, err)
+		return nil
+	}
+
+	resp := serveRequest(handler, req.Request)
+	diff, err := expected.Match(resp)
+	if err != nil {
+		t.Fatalf("error matching response with %s: %v", respname, err)
+		return nil
+	}
+	if !diff.Empty() {
+		t.Errorf("response of %s does not match %s:\n%s", reqname, respname, diff)
+	}
+	for _, m := range []*Matter{req.Matter, expected.Matter} {
+		if err := m.CheckAssertions(resp); err != nil {
+			t.Errorf("response of %s fails the assertions of %s:\n%v", reqname, m.Name, err)
+		}
+	}
+	return resp
+}
+
+// serveRequest serves the request fixture with the handler,
+// the response has the request as the handler got it
+func serveRequest(handler http.Handler, req *http.Request) *http.Response {
+	r := serverRequest(req)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	resp := rec.Result()
+	resp.Request = r
+	return resp
+}
+
+// serverRequest turns a parsed request fixture into a request as a server
+// receives it, like httptest.NewRequest does
+func serverRequest(req *http.Request) *http.Request {
+	r := req.Clone(req.Context())
+	if req.GetBody != nil {
+		r.Body, _ = req.GetBody()
+	}
+	r.RequestURI = r.URL.RequestURI()
+	r.RemoteAddr = "192.0.2.1:1234"
+	if r.Host == "" {
+		r.Host = "example.com"
+	}
+	return r
+}
+
+// ignoredHeaders returns the headers listed by the "@ignore-headers" directive
+func ignoredHeaders(m *Matter) []string {
+	directive, _ := m.Directive("ignore-headers")
+	return strings.FieldsFunc(directive, func(r rune) bool { return r == ',' || r == ' ' })
+}
@@ -0,0 +1,68 @@
+package httpmatter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// recordingTB keeps the errors instead of failing the test
+type recordingTB struct {
+	errors []string
+	logs   []string
+}
+
+func (tb *recordingTB) Logf(format string, args ...any) {
+	tb.logs = append(tb.logs, fmt.Sprintf(format, args...))
+}
+
+func (tb *recordingTB) Errorf(format string, args ...any) {
+	tb.errors = append(tb.errors, fmt.Sprintf(format, args...))
+}
+
+func (tb *recordingTB) Fatalf(format string, args ...any) {
+	tb.errors = append(tb.errors, fmt.Sprintf(format, args...))
+}
+
+// usersHandler creates users, status and name can be broken for tests
+func usersHandler(status int, suffix string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var user map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		id := "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/users/"+id)
+		w.Header().Set("X-Request-Id", "random")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"id":%q,"email":%q,"name":%q,"created_at":"2025-01-02T15:04:05Z"}`,
+			id, user["email"], user["name"]+suffix)
+	})
+}
+
+func TestServeFixture(t *testing.T) {
+	must := require.New(t)
+	resp := ServeFixture(t, usersHandler(http.StatusCreated, ""), "handler", "request_create_user", "response_create_user")
+	must.Equal(http.StatusCreated, resp.StatusCode)
+}
+
+func TestServeFixtureMismatch(t *testing.T) {
+	must := require.New(t)
+	tb := &recordingTB{}
+	ServeFixture(tb, usersHandler(http.StatusOK, "!"), "handler", "request_create_user", "response_create_user")
+	must.Len(tb.errors, 1)
+	report := tb.errors[0]
+	must.Contains(report, "response of request_create_user does not match response_create_user")
+	must.Contains(report, "status differs:\n--- expected\n+++ actual\n- 201 Created\n+ 200 OK\n")
+	must.Contains(report, "body differs:")
+	must.Contains(report, `-   "name": "Jane"`)
+	must.Contains(report, `+   "name": "Jane!"`)
+	must.NotContains(report, "{{@uuid}}")
+	must.False(strings.Contains(report, "headers differ:"), report)
+}
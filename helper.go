@@ -32,3 +32,17 @@ func makeMatter(matter matterer, opts ...Option) error {
 	}
 	return nil
 }
+
+// findNamespace returns the first namespace that has a fixture with the given name
+func findNamespace(namespaces []string, name string) (string, error) {
+	for _, namespace := range namespaces {
+		err := NewMatter(namespace, name).Validate()
+		if err == nil {
+			return namespace, nil
+		}
+		if !ErrReadingFile().Is(err) {
+			return "", err
+		}
+	}
+	return "", ErrReadingFile().WithData("name", name).WithData("namespaces", namespaces)
+}
@@ -24,6 +24,15 @@ func init() {
 	})
 }
 
+// tempNamespace returns a namespace in t.TempDir(), so the fixtures a
+// test writes are removed with it and never land in testdata
+func tempNamespace(t *testing.T) string {
+	t.Helper()
+	namespace, err := filepath.Rel(config.BaseDir, t.TempDir())
+	require.NoError(t, err)
+	return namespace
+}
+
 func TestEnvFile(t *testing.T) {
 	must := require.New(t)
 	mock, err := Response("basic", "response_only_body")
@@ -2,8 +2,11 @@ package httpmatter
 
 import (
 	"fmt"
+	"maps"
 	"net/http"
-	"testing"
+	"net/http/httptest"
+	"slices"
+	"sync"
 
 	"github.com/jarcoal/httpmock"
 )
@@ -15,111 +18,347 @@ type trip struct {
 	req       *RequestMatter
 	resps     []*ResponseMatter
 	responder responder
+	query     queryMatch
+	// min and max are how often the trip must and may respond,
+	// a max of unlimited keeps the trip after use, see Times and AtLeast
+	min, max int
+	// calls counts how often the trip responded
+	calls int
+}
+
+// unlimited is the max of a trip which responds any number of times
+const unlimited = -1
+
+// bodyMatch returns the body match mode picked by the request fixture
+func (t *trip) bodyMatch() string {
+	if mode, ok := t.req.Directive("match-body"); ok && mode != "" {
+		return mode
+	}
+	return MatchBodyNone
+}
+
+// validate checks the match directives and every placeholder of the fixture
+func (t *trip) validate() error {
+	if mode := t.bodyMatch(); !isBodyMatch(mode) {
+		return fmt.Errorf("unknown body match mode %q", mode)
+	}
+	directive, _ := t.req.Directive("match-query")
+	query, err := parseQueryMatch(directive)
+	if err != nil {
+		return err
+	}
+	t.query.ordered = query.ordered
+	t.query.ignore = append(t.query.ignore, query.ignore...)
+	for _, s := range placeholderExpr.FindAllString(t.req.content, -1) {
+		p, _ := parsePlaceholder(s)
+		if p.kind == PlaceholderType && slices.Contains(jsonTypes, p.arg) {
+			continue
+		}
+		if _, err := p.expr(anyValue); err != nil {
+			return fmt.Errorf("invalid placeholder %s: %w", s, err)
+		}
+	}
+	return nil
+}
+
+// matches reports whether the incoming request satisfies the request fixture.
+// Method, URL and body (see bodyMatch) are compared, headers only when
+// their value holds a placeholder. Named placeholders are stored in captures.
+// With anyHost the scheme and host are not compared
+func (t *trip) matches(r *http.Request, body []byte, captures map[string]string, anyHost bool) bool {
+	want := t.req.URL
+	if r.Method != t.req.Method {
+		return false
+	}
+	if !anyHost && !matchValue(want.Scheme+"://"+want.Host, r.URL.Scheme+"://"+r.URL.Host, anyValue, captures) {
+		return false
+	}
+	if !matchValue(orRoot(want.Path), orRoot(r.URL.Path), anySegment, captures) {
+		return false
+	}
+	if !matchQuery(fixtureQuery(want), r.URL.Query(), t.query, captures) {
+		return false
+	}
+	for name := range t.req.Header {
+		value := t.req.Header.Get(name)
+		if hasPlaceholder(value) && !matchValue(value, r.Header.Get(name), anyValue, captures) {
+			return false
+		}
+	}
+	mode := t.bodyMatch()
+	if mode == MatchBodyNone {
+		return true
+	}
+	expected, err := t.req.BodyBytes()
+	if err != nil {
+		return false
+	}
+	return matchBody(mode, expected, body, captures)
+}
+
+func orRoot(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
 }
 
 type HTTP struct {
-	t          testing.TB
-	namespaces []string
-	trip       *trip
-	trips      []*trip
+	t             TB
+	namespaces    []string
+	trip          *trip
+	trips         []*trip
+	verify        bool
+	ignoreHeaders []string
+	ignoreQuery   []string
+	global        bool
+	server        *httptest.Server
+	vars          map[string]any
+	upstream      http.RoundTripper
+
+	mu      sync.Mutex
+	pending []*trip
+	calls   []*call
+	// counts are the calls per key, calls only keeps the last ones
+	counts   map[string]int
+	dropped  int
+	captures map[string]string
+	updating sync.Mutex
 }
 
-func NewHTTP(t testing.TB, namespaces ...string) *HTTP {
+func NewHTTP(t TB, namespaces ...string) *HTTP {
 	return &HTTP{
 		t:          t,
 		namespaces: namespaces,
 		trip:       nil,
 		trips:      []*trip{},
+		captures:   make(map[string]string),
+		counts:     make(map[string]int),
+		vars:       make(map[string]any),
+		upstream:   httpmock.InitialTransport,
 	}
 }
 
 func (h *HTTP) Init() {
-	groups := make(map[string][]*trip)
-
 	for _, trip := range h.trips {
-		err := makeMatter(trip.req, WithTB(h.t))
+		h.initTrip(trip)
+	}
+	h.pending = slices.Clone(h.trips)
+
+	if h.global {
+		httpmock.Activate()
+		// Every request goes through roundTrip, which picks the trip
+		httpmock.RegisterNoResponder(h.roundTrip)
+	}
+}
+
+// initTrip reads the fixtures of the trip and checks its directives
+func (h *HTTP) initTrip(trip *trip) {
+	err := makeMatter(trip.req, WithTB(h.t), WithVariables(h.vars))
+	if err != nil {
+		h.t.Fatalf("error creating matter for %s: %v", trip.req.Name, err)
+	}
+	for _, resp := range trip.resps {
+		err := makeMatter(resp, WithTB(h.t), WithVariables(h.vars))
 		if err != nil {
-			h.t.Fatalf("error creating matter for %s: %v", trip.req.Name, err)
-		}
-		for _, resp := range trip.resps {
-			err := makeMatter(resp, WithTB(h.t))
-			if err != nil {
-				h.t.Fatalf("error creating matter for %s: %v", resp.Name, err)
-			}
+			h.t.Fatalf("error creating matter for %s: %v", resp.Name, err)
 		}
+	}
+	trip.query.ignore = slices.Clone(h.ignoreQuery)
+	if err := trip.validate(); err != nil {
+		h.t.Fatalf("error in request %s: %v", trip.req.Name, err)
+	}
+}
 
-		key := h.toKey(trip.req)
-		groups[key] = append(groups[key], trip)
-	}
-
-	for key, group := range groups {
-		h.t.Logf("Registering responder for %s with %d trips", key, len(group))
-		httpmock.RegisterResponder(
-			// Because group always have same Method and URL,
-			// we can use the first trip to get the method and url
-			group[0].req.Method,
-			group[0].req.URL.String(),
-			func(r *http.Request) (*http.Response, error) {
-				if len(group) == 0 {
-					h.t.Fatalf("no more trips for %s", key)
-				}
-				chosen := group[0].responder(r, group[0].req, group[0].resps)
-				// Now when the first call is responded, we need to remove
-				// the trip from the group
-				before := len(group)
-				group = group[1:]
-				after := len(group)
-				if before == after {
-					h.t.Fatalf("group did not change length")
-				}
-
-				h.t.Logf("group length changed from %d to %d", before, after)
-				return chosen.Response, nil
-			},
-		)
-	}
-
-	httpmock.Activate()
+// Global makes Init replace http.DefaultTransport for the whole process,
+// so code which does not take a client or transport is mocked as well.
+// Tests using it cannot run in parallel
+func (h *HTTP) Global() *HTTP {
+	h.global = true
+	return h
 }
-func (h *HTTP) Destroy() {
-	// Verify that every registered responder was actually invoked at least once.
-	// This helps ensure test coverage for multi-step flows (e.g., paginated calls).
-	callCounts := httpmock.GetCallCountInfo()
-	httpmock.DeactivateAndReset()
 
-	var missing []string
-	for _, trip := range h.trips {
+// Transport returns a http.RoundTripper which serves the trips of this HTTP
+// only, so tests using their own transport can run in parallel
+func (h *HTTP) Transport() http.RoundTripper {
+	return roundTripperFunc(h.roundTrip)
+}
+
+// Client returns a *http.Client using Transport
+func (h *HTTP) Client() *http.Client {
+	return &http.Client{Transport: h.Transport()}
+}
+
+type roundTripperFunc func(r *http.Request) (*http.Response, error)
+
+func (fn roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return fn(r)
+}
+
+func (h *HTTP) roundTrip(r *http.Request) (*http.Response, error) {
+	return h.respond(r, false)
+}
+
+// respond responds with the first pending trip in order which matches
+// the request. Each trip is used only once
+func (h *HTTP) respond(r *http.Request, anyHost bool) (*http.Response, error) {
+	resp, err := h.tryRespond(r, anyHost)
+	if err != nil && ErrNoTrip().Is(err) {
+		request := r.Method + " " + r.URL.String()
+		h.mu.Lock()
+		h.logCall(&call{key: h.usedKey(r, anyHost), request: request})
+		h.mu.Unlock()
+		h.t.Errorf("no trip matches %s %s", r.Method, r.URL)
+	}
+	return resp, err
+}
+
+// tryRespond is respond without failing the test,
+// it returns ErrNoTrip when no pending trip matches. Only picking the trip
+// holds the lock, so responders, updates and injected delays may call
+// back into the mock
+func (h *HTTP) tryRespond(r *http.Request, anyHost bool) (*http.Response, error) {
+	body, err := peekBody(&r.Body)
+	if err != nil {
+		return nil, err
+	}
+	trip, c, captures := h.pick(r, body, anyHost)
+	if trip == nil {
+		return nil, ErrNoTrip().WithData("request", r.Method+" "+r.URL.String())
+	}
+	if h.verify {
+		if report := verifyRequest(trip.req, r, body, h.ignoreHeaders, trip.query); report != "" {
+			h.t.Errorf("request %s does not match %s:\n%s", c.key, trip.req.Name, report)
+		}
+	}
+	// updates replace the fixtures of the trip, see update
+	h.mu.Lock()
+	resps := slices.Clone(trip.resps)
+	h.mu.Unlock()
+	chosen := trip.responder(r, trip.req, resps)
+	h.mu.Lock()
+	c.resp = chosen.Name
+	h.mu.Unlock()
+	if chosen.fault.failing() {
+		return nil, chosen.fault.wait(r.Context())
+	}
+	var resp *http.Response
+	if Updating() {
+		resp, err = h.update(r, body, trip, chosen, anyHost)
+	} else {
+		// The response is rendered for every call, so it can use the request
+		resp, err = chosen.render(r, captures)
+		if err != nil {
+			h.t.Errorf("error rendering %s for %s: %v", chosen.Name, c.key, err)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := trip.req.CheckAssertions(resp); err != nil {
+		h.t.Errorf("%s fails the assertions of %s:\n%v", chosen.Name, trip.req.Name, err)
+	}
+	if err := chosen.fault.wait(r.Context()); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+// pick takes the first pending trip in order which matches the request
+// and counts the call, it returns a nil trip when none matches
+func (h *HTTP) pick(r *http.Request, body []byte, anyHost bool) (*trip, *call, map[string]string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, trip := range h.pending {
+		captures := make(map[string]string)
+		if !trip.matches(r, body, captures, anyHost) {
+			continue
+		}
 		key := h.toKey(trip.req)
-		if callCounts[key] == 0 {
-			missing = append(missing, trip.req.Name)
+		maps.Copy(h.captures, captures)
+		trip.calls++
+		// Now when the trip responded as often as it may, we need to remove it
+		if trip.max != unlimited && trip.calls >= trip.max {
+			h.pending = slices.Delete(h.pending, i, i+1)
 		}
+		c := &call{key: key, request: r.Method + " " + r.URL.String(), trip: trip}
+		h.logCall(c)
+		h.t.Logf("%s responded by %s, %d trips pending", key, trip.req.Name, len(h.pending))
+		return trip, c, captures
+	}
+	return nil, nil, nil
+}
+
+// Capture returns the last value matched by a named placeholder
+// like {{id=@any}}
+func (h *HTTP) Capture(name string) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.captures[name]
+}
+
+// Destroy stops mocking and fails the test when the trips were not used
+// as added: trips which never responded, keys which got more calls than
+// they have trips, together with every call in order
+func (h *HTTP) Destroy() {
+	if h.global {
+		httpmock.DeactivateAndReset()
 	}
-	if len(missing) > 0 {
-		h.t.Fatalf("uninvoked HTTP mocks: %v", missing)
+	if h.server != nil {
+		h.server.Close()
+	}
+	h.mu.Lock()
+	report := h.usageReport()
+	h.mu.Unlock()
+	if report != "" {
+		h.t.Fatalf("HTTP mocks not used as added:\n%s", report)
 	}
 }
 
 func (h *HTTP) Add(reqname string, respnames ...string) *HTTP {
 	h.t.Logf("Adding request %s with %d responses", reqname, len(respnames))
-	if h.trip != nil {
-		h.t.Fatalf(
-			"request set already pending:%v, use Respond() to continue",
-			h.trip.req.Name)
-	}
 	req := h.newRequest(reqname)
 	resps := make([]*ResponseMatter, len(respnames))
 	for i, respname := range respnames {
 		resps[i] = h.newResponse(respname)
 	}
+	return h.addTrip(req, resps)
+}
+
+// addRoute is Add for a route, its fixtures are taken from the namespace
+// of the route only
+func (h *HTTP) addRoute(route Route) *HTTP {
+	h.t.Logf("Adding request %s/%s with %d responses", route.Namespace, route.Request, len(route.Responses))
+	resps := make([]*ResponseMatter, len(route.Responses))
+	for i, respname := range route.Responses {
+		resps[i] = NewResponseMatter(route.Namespace, respname)
+	}
+	return h.addTrip(NewRequestMatter(route.Namespace, route.Request), resps)
+}
+
+func (h *HTTP) addTrip(req *RequestMatter, resps []*ResponseMatter) *HTTP {
+	if h.trip != nil {
+		h.t.Fatalf(
+			"request set already pending:%v, use Respond() to continue",
+			h.trip.req.Name)
+	}
 	reqSet := &trip{
 		req:       req,
 		resps:     resps,
 		responder: nil,
+		min:       1,
+		max:       1,
 	}
 	h.trip = reqSet
 	return h
 }
 
+// Respond finishes the trip, fn picks the response of each call and
+// DefaultResponder is used when it is nil. fn runs without holding the
+// mock, so it may call Capture or the mock itself, and concurrent
+// requests may run it concurrently
 func (h *HTTP) Respond(fn responder) *HTTP {
 	if h.trip == nil {
 		h.t.Fatalf("no requests found")
@@ -134,36 +373,109 @@ func (h *HTTP) Respond(fn responder) *HTTP {
 	return h
 }
 
+// Times makes the trip respond exactly n times instead of once, it can be
+// called before or after Respond. Trips with the same request respond in
+// the order they were added, the next one once this one is used up
+func (h *HTTP) Times(n int) *HTTP {
+	if n < 1 {
+		h.t.Fatalf("Times needs at least 1, got %d", n)
+		return h
+	}
+	if trip := h.lastTrip("Times"); trip != nil {
+		trip.min, trip.max = n, n
+	}
+	return h
+}
+
+// AtLeast makes the trip respond any number of times, Destroy fails when
+// it responded less than n times. Later trips with the same request are
+// never reached
+func (h *HTTP) AtLeast(n int) *HTTP {
+	if n < 0 {
+		h.t.Fatalf("AtLeast needs 0 or more, got %d", n)
+		return h
+	}
+	if trip := h.lastTrip("AtLeast"); trip != nil {
+		trip.min, trip.max = n, unlimited
+	}
+	return h
+}
+
+// Always makes the trip respond any number of times, also never,
+// e.g. for polling or long running servers
+func (h *HTTP) Always() *HTTP {
+	return h.AtLeast(0)
+}
+
+// Optional lets Destroy pass when the trip never responded,
+// it keeps how often the trip may respond
+func (h *HTTP) Optional() *HTTP {
+	if trip := h.lastTrip("Optional"); trip != nil {
+		trip.min = 0
+	}
+	return h
+}
+
+// lastTrip returns the trip being added, or else the last added one
+func (h *HTTP) lastTrip(method string) *trip {
+	if h.trip != nil {
+		return h.trip
+	}
+	if len(h.trips) == 0 {
+		h.t.Fatalf("%s needs a trip, call Add first", method)
+		return nil
+	}
+	return h.trips[len(h.trips)-1]
+}
+
+// Fail is Respond for a trip which fails every request with err instead
+// of answering, e.g. Fail(NetworkError(NetworkConnectionRefused)). A timeout
+// error waits for the deadline of the request context and returns its error
+func (h *HTTP) Fail(err error) *HTTP {
+	failure := ErrorResponse(err)
+	return h.Respond(func(*http.Request, *RequestMatter, []*ResponseMatter) *ResponseMatter {
+		return failure
+	})
+}
+
+// Verify checks every outgoing request against its full request fixture,
+// headers, query and body, and fails the test with a diff when they differ.
+// Headers which change on every call (e.g. Date or X-Request-Id) can be ignored
+func (h *HTTP) Verify(ignoreHeaders ...string) *HTTP {
+	h.verify = true
+	h.ignoreHeaders = append(h.ignoreHeaders, ignoreHeaders...)
+	return h
+}
+
+// IgnoreQuery never compares the given query parameters,
+// e.g. cache busters or signatures which change on every call
+func (h *HTTP) IgnoreQuery(params ...string) *HTTP {
+	h.ignoreQuery = append(h.ignoreQuery, params...)
+	return h
+}
+
 // newRequest check for the request in each namespace
 // It gives priority to the first namespace that has the request
 func (h *HTTP) newRequest(name string) *RequestMatter {
-	for _, namespace := range h.namespaces {
-		req := NewRequestMatter(namespace, name)
-		if err := req.Validate(); err == nil {
-			return req
-		} else if ErrReadingFile().Is(err) {
-			continue
-		}
+	namespace, err := findNamespace(h.namespaces, name)
+	if err != nil && ErrReadingFile().Is(err) {
+		h.t.Fatalf("no request found for %s in %v", name, h.namespaces)
+	} else if err != nil {
+		h.t.Fatalf("error creating matter for %s: %v", name, err)
 	}
-	h.t.Fatalf("no request found for %s in %v", name, h.namespaces)
-	return nil
+	return NewRequestMatter(namespace, name)
 }
 
 // newResponse check for the response in each namespace
 // It gives priority to the first namespace that has the response
 func (h *HTTP) newResponse(name string) *ResponseMatter {
-	for _, namespace := range h.namespaces {
-		resp := NewResponseMatter(namespace, name)
-		if err := resp.Validate(); err == nil {
-			return resp
-		} else if ErrReadingFile().Is(err) {
-			continue
-		} else {
-			h.t.Fatalf("error creating matter: %v", err)
-		}
+	namespace, err := findNamespace(h.namespaces, name)
+	if err != nil && ErrReadingFile().Is(err) {
+		h.t.Fatalf("no response found for %s in %v", name, h.namespaces)
+	} else if err != nil {
+		h.t.Fatalf("error creating matter for %s: %v", name, err)
 	}
-	h.t.Fatalf("no response found for %s in %v", name, h.namespaces)
-	return nil
+	return NewResponseMatter(namespace, name)
 }
 
 func (h *HTTP) toKey(req *RequestMatter) string {
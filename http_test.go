@@ -2,10 +2,15 @@ package httpmatter
 
 import (
 	"bytes"
+	"fmt"
 	"io"
+	"net/http"
+	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNormalizeLineEndingsContent(t *testing.T) {
@@ -160,3 +165,308 @@ Access-Control-Allow-Origin: *
 	should.Equal("Mon, 18 Aug 2025 13:44:32 GMT", resp.Header.Get("Date"))
 	should.Equal("*", resp.Header.Get("Access-Control-Allow-Origin"))
 }
+
+func TestHTTPMatchBody(t *testing.T) {
+	h := NewHTTP(t, "matching").
+		Global().
+		Add("request_order_games", "response_order_games").
+		Respond(nil).
+		Add("request_order_books", "response_order_books").
+		Respond(nil).
+		Add("request_signup", "response_signup").
+		Respond(nil)
+	h.Init()
+	defer h.Destroy()
+
+	must := require.New(t)
+	resp, err := http.Post("https://example.com/api/order", "application/json",
+		strings.NewReader(`{"quantity":1,"product":"books"}`))
+	must.NoError(err)
+	body, err := io.ReadAll(resp.Body)
+	must.NoError(err)
+	must.Equal(201, resp.StatusCode)
+	must.Contains(string(body), `"books"`)
+
+	resp, err = http.Post("https://example.com/api/order", "application/json",
+		strings.NewReader(`{ "product": "games", "quantity": 2 }`))
+	must.NoError(err)
+	body, err = io.ReadAll(resp.Body)
+	must.NoError(err)
+	must.Contains(string(body), `"games"`)
+
+	resp, err = http.Post("https://example.com/signup", "application/x-www-form-urlencoded",
+		strings.NewReader("plan=pro&name=john"))
+	must.NoError(err)
+	must.Equal(204, resp.StatusCode)
+}
+
+func TestMatchBody(t *testing.T) {
+	should := assert.New(t)
+	should.True(matchBody(MatchBodyNone, []byte("a"), []byte("b"), nil))
+	should.True(matchBody(MatchBodyExact, []byte("a=1\n"), []byte("a=1"), nil))
+	should.False(matchBody(MatchBodyExact, []byte("a=1"), []byte("a=2"), nil))
+	should.True(matchBody(MatchBodyJSON, []byte(`{"a":1,"b":[1,2]}`), []byte(`{ "b": [1, 2], "a": 1.0 }`), nil))
+	should.False(matchBody(MatchBodyJSON, []byte(`{"b":[1,2]}`), []byte(`{"b":[2,1]}`), nil))
+	should.True(matchBody(MatchBodyForm, []byte("a=1&b=2"), []byte("b=2&a=1"), nil))
+	should.False(matchBody(MatchBodyForm, []byte("a=1&b=2"), []byte("a=1"), nil))
+}
+
+func TestHTTPMatchQuery(t *testing.T) {
+	t.Parallel()
+	h := NewHTTP(t, "matching").
+		IgnoreQuery("sig").
+		Add("request_search", "response_search").
+		Respond(nil)
+	h.Init()
+	defer h.Destroy()
+
+	must := require.New(t)
+	resp, err := h.Client().Get("https://example.com/search?page=1&cb=1755524672&tag=http&sig=abc&tag=go")
+	must.NoError(err)
+	must.Equal(200, resp.StatusCode)
+}
+
+func TestMatchQuery(t *testing.T) {
+	should := assert.New(t)
+	parse := func(s string) url.Values {
+		values, err := url.ParseQuery(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return values
+	}
+	should.True(matchQuery(parse("a=1&b=2"), parse("b=2&a=1"), queryMatch{}, nil))
+	should.True(matchQuery(parse("a=1&a=2"), parse("a=2&a=1"), queryMatch{}, nil))
+	should.False(matchQuery(parse("a=1&a=2"), parse("a=2&a=1"), queryMatch{ordered: true}, nil))
+	should.False(matchQuery(parse("a=1&a=2"), parse("a=1&a=1"), queryMatch{}, nil))
+	should.True(matchQuery(parse("a={{@any}}&a=1"), parse("a=1&a=9"), queryMatch{}, nil))
+	should.True(matchQuery(parse("a=1"), parse("a=1&cb=9"), queryMatch{ignore: []string{"cb"}}, nil))
+	should.False(matchQuery(parse("a=1"), parse("a=1&cb=9"), queryMatch{}, nil))
+
+	// Placeholders of the fixture are not decoded, + stays a regex operator
+	fixture := func(s string) url.Values {
+		return fixtureQuery(&url.URL{RawQuery: s})
+	}
+	should.True(matchQuery(fixture(`id={{@regex(^\d+$)}}`), parse("id=42"), queryMatch{}, nil))
+	should.True(matchQuery(fixture(`q={{@regex(^a\+b$)}}`), parse("q=a%2Bb"), queryMatch{}, nil))
+	should.False(matchQuery(fixture(`q={{@regex(^a\+b$)}}`), parse("q=a+b"), queryMatch{}, nil))
+	should.True(matchQuery(fixture(`q={{@regex(^(a&b|c%d)$)}}&p=1`), parse("q=a%26b&p=1"), queryMatch{}, nil))
+	should.True(matchQuery(fixture("q=a+b"), parse("q=a%20b"), queryMatch{}, nil))
+
+	qm, err := parseQueryMatch("ordered ignore=cb,sig")
+	should.NoError(err)
+	should.Equal(queryMatch{ordered: true, ignore: []string{"cb", "sig"}}, qm)
+	_, err = parseQueryMatch("sorted")
+	should.Error(err)
+}
+
+func TestHTTPTransportIsolation(t *testing.T) {
+	for _, name := range []string{"books", "games"} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			h := NewHTTP(t, "matching").
+				Add("request_order_"+name, "response_order_"+name).
+				Respond(nil)
+			h.Init()
+			defer h.Destroy()
+
+			must := require.New(t)
+			quantity := map[string]string{"books": "1", "games": "2"}[name]
+			resp, err := h.Client().Post("https://example.com/api/order", "application/json",
+				strings.NewReader(`{"product":"`+name+`","quantity":`+quantity+`}`))
+			must.NoError(err)
+			body, err := io.ReadAll(resp.Body)
+			must.NoError(err)
+			must.Contains(string(body), name)
+		})
+	}
+}
+
+func TestHTTPDestroyReportsUsage(t *testing.T) {
+	must := require.New(t)
+	tb := &recordingTB{}
+	h := NewHTTP(tb, "matching").
+		Add("request_order_books", "response_order_books").
+		Respond(nil).
+		Add("request_order_books", "response_order_books").
+		Respond(nil).
+		Add("request_order_games", "response_order_games").
+		Respond(nil)
+	h.Init()
+	client := h.Client()
+
+	order := func(product string, quantity int) {
+		resp, err := client.Post("https://example.com/api/order", "application/json",
+			strings.NewReader(fmt.Sprintf(`{"product":%q,"quantity":%d}`, product, quantity)))
+		if err == nil {
+			resp.Body.Close()
+		}
+	}
+	order("books", 1)
+	_, _ = client.Get("https://example.com/api/unknown")
+	_, _ = client.Get("https://example.com/api/unknown?page=2")
+	h.Destroy()
+
+	// every request matching no trip is counted under one key
+	must.Len(h.counts, 2)
+	must.Len(tb.errors, 3)
+	must.Contains(tb.errors[0], "no trip matches GET https://example.com/api/unknown")
+	must.Equal(`HTTP mocks not used as added:
+unused trips:
+  request_order_books (POST https://example.com/api/order), expected exactly 1
+  request_order_games (POST https://example.com/api/order), expected exactly 1
+extra calls:
+  requests matching no trip: 2 calls, expected at most 0
+calls in order:
+  1. POST https://example.com/api/order -> request_order_books / response_order_books
+  2. GET https://example.com/api/unknown -> no trip
+  3. GET https://example.com/api/unknown?page=2 -> no trip
+`, tb.errors[2])
+}
+
+func TestHTTPDestroyReportsExtraCalls(t *testing.T) {
+	must := require.New(t)
+	tb := &recordingTB{}
+	h := NewHTTP(tb, "matching").
+		Add("request_order_books", "response_order_books").
+		Respond(nil)
+	h.Init()
+	client := h.Client()
+	for range 2 {
+		resp, err := client.Post("https://example.com/api/order", "application/json",
+			strings.NewReader(`{"product":"books","quantity":1}`))
+		if err == nil {
+			resp.Body.Close()
+		}
+	}
+	h.Destroy()
+
+	must.Len(tb.errors, 2)
+	must.Contains(tb.errors[1], "extra calls:\n  POST https://example.com/api/order: 2 calls, expected at most 1\n")
+	must.NotContains(tb.errors[1], "unused trips:")
+	must.Contains(tb.errors[1], "  2. POST https://example.com/api/order -> no trip\n")
+}
+
+func TestHTTPDestroyReportsExtraCallsOfPlaceholders(t *testing.T) {
+	must := require.New(t)
+	tb := &recordingTB{}
+	h := NewHTTP(tb, "placeholders").
+		Add("request_get_user", "response_get_user").
+		Respond(nil)
+	h.Init()
+	for _, id := range []string{"1", "2"} {
+		req, err := http.NewRequest(http.MethodGet, "https://example.com/users/"+id, nil)
+		must.NoError(err)
+		req.Header.Set("X-Request-Id", "0f8fad5b-d9cb-469f-a165-70867728950e")
+		if resp, err := h.Client().Do(req); err == nil {
+			resp.Body.Close()
+		}
+	}
+	h.Destroy()
+
+	must.Len(tb.errors, 2)
+	key := h.toKey(h.trips[0].req)
+	must.Contains(tb.errors[1], "extra calls:\n  "+key+": 2 calls, expected at most 1\n")
+	must.Contains(tb.errors[1], "  2. GET https://example.com/users/2 -> no trip\n")
+}
+
+func TestHTTPDestroyKeepsLastCalls(t *testing.T) {
+	must := require.New(t)
+	tb := &recordingTB{}
+	h := NewHTTP(tb, "matching").
+		Add("request_order_books", "response_order_books").Times(maxLoggedCalls + 5).
+		Respond(nil)
+	h.Init()
+	for range maxLoggedCalls + 6 {
+		resp, err := h.Client().Post("https://example.com/api/order", "application/json",
+			strings.NewReader(`{"product":"books","quantity":1}`))
+		if err == nil {
+			resp.Body.Close()
+		}
+	}
+	h.Destroy()
+
+	must.Len(h.calls, maxLoggedCalls)
+	must.Len(tb.errors, 2)
+	must.Contains(tb.errors[1], fmt.Sprintf("POST https://example.com/api/order: %d calls, expected at most %d\n",
+		maxLoggedCalls+6, maxLoggedCalls+5))
+	must.Contains(tb.errors[1], "calls in order:\n  ... 6 earlier calls\n  7. POST")
+	must.Contains(tb.errors[1], fmt.Sprintf("  %d. POST https://example.com/api/order -> no trip\n", maxLoggedCalls+6))
+}
+
+func TestHTTPCardinality(t *testing.T) {
+	order := func(client *http.Client, product string, quantity int) {
+		resp, err := client.Post("https://example.com/api/order", "application/json",
+			strings.NewReader(fmt.Sprintf(`{"product":%q,"quantity":%d}`, product, quantity)))
+		if err == nil {
+			resp.Body.Close()
+		}
+	}
+
+	t.Run("used as added", func(t *testing.T) {
+		must := require.New(t)
+		tb := &recordingTB{}
+		h := NewHTTP(tb, "matching").
+			Add("request_order_books", "response_order_books").Times(2).
+			Respond(nil).
+			Add("request_order_games", "response_order_games").
+			Respond(nil).
+			AtLeast(1).
+			Add("request_search", "response_search").
+			Respond(nil).
+			Optional()
+		h.Init()
+		for range 2 {
+			order(h.Client(), "books", 1)
+		}
+		for range 3 {
+			order(h.Client(), "games", 2)
+		}
+		h.Destroy()
+		must.Empty(tb.errors)
+	})
+
+	t.Run("not used as added", func(t *testing.T) {
+		must := require.New(t)
+		tb := &recordingTB{}
+		h := NewHTTP(tb, "matching").
+			Add("request_order_books", "response_order_books").Times(3).
+			Respond(nil).
+			Add("request_order_games", "response_order_games").Always().
+			Respond(nil)
+		h.Init()
+		for range 2 {
+			order(h.Client(), "books", 1)
+		}
+		h.Destroy()
+		must.Len(tb.errors, 1)
+		must.Contains(tb.errors[0], "too few calls:\n  request_order_books (POST https://example.com/api/order): 2 calls, expected exactly 3\n")
+		must.NotContains(tb.errors[0], "request_order_games (")
+		must.NotContains(tb.errors[0], "extra calls:")
+	})
+
+	t.Run("used up", func(t *testing.T) {
+		must := require.New(t)
+		tb := &recordingTB{}
+		h := NewHTTP(tb, "matching").
+			Add("request_order_books", "response_order_books").Times(2).
+			Respond(nil)
+		h.Init()
+		for range 3 {
+			order(h.Client(), "books", 1)
+		}
+		h.Destroy()
+		must.Len(tb.errors, 2)
+		must.Contains(tb.errors[0], "no trip matches POST https://example.com/api/order")
+		must.Contains(tb.errors[1], "extra calls:\n  POST https://example.com/api/order: 3 calls, expected at most 2\n")
+		must.Contains(tb.errors[1], "  3. POST https://example.com/api/order -> no trip\n")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		must := require.New(t)
+		tb := &recordingTB{}
+		NewHTTP(tb, "matching").Times(1)
+		NewHTTP(tb, "matching").Add("request_order_books", "response_order_books").Times(0)
+		must.Equal([]string{"Times needs a trip, call Add first", "Times needs at least 1, got 0"}, tb.errors)
+	})
+}
@@ -0,0 +1,271 @@
+package httpmatter
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Body match modes, picked by a request fixture with the
+// "// @match-body <mode>" front matter directive
+const (
+	// MatchBodyNone ignores the body, trips are told apart by order only
+	MatchBodyNone = "none"
+	// MatchBodyExact compares the body byte by byte
+	MatchBodyExact = "exact"
+	// MatchBodyJSON compares the body as JSON, ignoring key order and whitespace
+	MatchBodyJSON = "json"
+	// MatchBodyForm compares the body as url encoded form fields
+	MatchBodyForm = "form"
+	// MatchBodyXML compares the body as XML, ignoring prefixes, attribute
+	// order and whitespace between elements
+	MatchBodyXML = "xml"
+)
+
+func isBodyMatch(mode string) bool {
+	switch mode {
+	case MatchBodyNone, MatchBodyExact, MatchBodyJSON, MatchBodyForm, MatchBodyXML:
+		return true
+	}
+	return false
+}
+
+// matchBody reports whether the actual body matches the expected one
+// using the given mode, JSON and form values may hold placeholders
+func matchBody(mode string, expected, actual []byte, captures map[string]string) bool {
+	switch mode {
+	case MatchBodyExact:
+		return bytes.Equal(trimLineBreaks(expected), trimLineBreaks(actual))
+	case MatchBodyJSON:
+		return equalJSON(expected, actual, captures)
+	case MatchBodyForm:
+		return equalForm(expected, actual, captures)
+	case MatchBodyXML:
+		return equalXML(expected, actual, captures)
+	}
+	return true
+}
+
+// trimLineBreaks drops the trailing line break every fixture file ends with
+func trimLineBreaks(b []byte) []byte {
+	return bytes.TrimRight(b, "\r\n")
+}
+
+func equalJSON(expected, actual []byte, captures map[string]string) bool {
+	var e, a any
+	if err := json.Unmarshal(expected, &e); err != nil {
+		return false
+	}
+	if err := json.Unmarshal(actual, &a); err != nil {
+		return false
+	}
+	return matchJSON(e, a, captures)
+}
+
+func equalForm(expected, actual []byte, captures map[string]string) bool {
+	e, err := url.ParseQuery(string(bytes.TrimSpace(expected)))
+	if err != nil {
+		return false
+	}
+	a, err := url.ParseQuery(string(bytes.TrimSpace(actual)))
+	if err != nil {
+		return false
+	}
+	return matchValues(e, a, anyValue, captures)
+}
+
+// matchValues compares url values key by key, values of a repeated key
+// are compared in order and may hold placeholders
+func matchValues(expected, actual url.Values, wildcard string, captures map[string]string) bool {
+	if len(expected) != len(actual) {
+		return false
+	}
+	for key, values := range expected {
+		got, ok := actual[key]
+		if !ok || len(got) != len(values) {
+			return false
+		}
+		for i := range values {
+			if !matchValue(values[i], got[i], wildcard, captures) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// queryMatch tells how query parameters are compared, picked by a request
+// fixture with "// @match-query [ordered] [ignore=<param>,<param>]".
+// Parameters are compared as a multiset by default, so neither the order of
+// the parameters nor the order of the values of a repeated key matters
+type queryMatch struct {
+	// ordered requires the values of a repeated key to keep their order
+	ordered bool
+	// ignore lists parameters which are never compared, e.g. cache busters
+	ignore []string
+}
+
+func parseQueryMatch(directive string) (queryMatch, error) {
+	qm := queryMatch{}
+	for _, field := range strings.Fields(directive) {
+		switch name, value, _ := strings.Cut(field, "="); name {
+		case "ordered":
+			qm.ordered = true
+		case "ignore":
+			qm.ignore = append(qm.ignore, strings.Split(value, ",")...)
+		default:
+			return qm, fmt.Errorf("unknown query match option %q", field)
+		}
+	}
+	return qm, nil
+}
+
+// without returns the values minus the ignored parameters
+func (qm queryMatch) without(values url.Values) url.Values {
+	out := url.Values{}
+	for key, vs := range values {
+		if !slices.Contains(qm.ignore, key) {
+			out[key] = vs
+		}
+	}
+	return out
+}
+
+// matchQuery compares query parameters, expected values may hold placeholders
+func matchQuery(expected, actual url.Values, qm queryMatch, captures map[string]string) bool {
+	expected, actual = qm.without(expected), qm.without(actual)
+	if qm.ordered {
+		return matchValues(expected, actual, anyValue, captures)
+	}
+	if len(expected) != len(actual) {
+		return false
+	}
+	for key, values := range expected {
+		got, ok := actual[key]
+		if !ok || len(got) != len(values) {
+			return false
+		}
+		// Literal values are paired first so placeholders
+		// do not take a value a literal needs
+		values = slices.Clone(values)
+		slices.SortStableFunc(values, func(a, b string) int {
+			return boolToInt(hasPlaceholder(a)) - boolToInt(hasPlaceholder(b))
+		})
+		used := make([]bool, len(got))
+		for _, value := range values {
+			found := false
+			for i := range got {
+				if !used[i] && matchValue(value, got[i], anyValue, captures) {
+					used[i], found = true, true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// xmlNode is an element of a XML body, names are resolved against
+// their namespace so the prefixes do not matter
+type xmlNode struct {
+	name     xml.Name
+	attrs    []xml.Attr
+	text     string
+	children []*xmlNode
+}
+
+// parseXML reads the root element, whitespace around text is trimmed
+// and namespace declarations are left out of the attributes
+func parseXML(body []byte) (*xmlNode, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	var root *xmlNode
+	var stack []*xmlNode
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := &xmlNode{name: t.Name}
+			for _, attr := range t.Attr {
+				if attr.Name.Space != "xmlns" && attr.Name.Local != "xmlns" {
+					node.attrs = append(node.attrs, attr)
+				}
+			}
+			slices.SortFunc(node.attrs, func(a, b xml.Attr) int {
+				return strings.Compare(a.Name.Space+" "+a.Name.Local, b.Name.Space+" "+b.Name.Local)
+			})
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, node)
+			} else if root == nil {
+				root = node
+			}
+			stack = append(stack, node)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(t)
+			}
+		}
+	}
+	if root == nil {
+		return nil, fmt.Errorf("no root element")
+	}
+	return root, nil
+}
+
+// equalXML compares XML bodies element by element, text and attribute
+// values of the expected side may hold placeholders
+func equalXML(expected, actual []byte, captures map[string]string) bool {
+	e, err := parseXML(expected)
+	if err != nil {
+		return false
+	}
+	a, err := parseXML(actual)
+	if err != nil {
+		return false
+	}
+	return matchXML(e, a, captures)
+}
+
+func matchXML(expected, actual *xmlNode, captures map[string]string) bool {
+	if expected.name != actual.name || len(expected.attrs) != len(actual.attrs) ||
+		len(expected.children) != len(actual.children) {
+		return false
+	}
+	for i, attr := range expected.attrs {
+		if attr.Name != actual.attrs[i].Name || !matchValue(attr.Value, actual.attrs[i].Value, anyValue, captures) {
+			return false
+		}
+	}
+	if !matchValue(strings.TrimSpace(expected.text), strings.TrimSpace(actual.text), anyValue, captures) {
+		return false
+	}
+	for i := range expected.children {
+		if !matchXML(expected.children[i], actual.children[i], captures) {
+			return false
+		}
+	}
+	return true
+}
@@ -2,10 +2,10 @@ package httpmatter
 
 import (
 	"bufio"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
-	"testing"
 )
 
 // Matter is a generic matter that can be used to store content and error
@@ -16,7 +16,20 @@ type Matter struct {
 	Namespace string
 	Name      string
 	Vars      map[string]any
-	tb        testing.TB
+	tb        TB
+	exchanges map[string]*exchange
+	incoming  *http.Request
+	params    map[string]string
+	// sanitizers run on the content before Save
+	sanitizers []Sanitizer
+	// reverseVars puts {{name}} back for values of Vars on Save
+	reverseVars bool
+	// canonical writes the content with Canonicalize on Save
+	canonical bool
+	// fetch gets the real response in update mode, see WithUpdate
+	fetch func() (*http.Response, error)
+	// assertions are the inline "??" lines, taken out of the content
+	assertions []Assertion
 }
 
 func NewMatter(namespace, name string) *Matter {
@@ -49,16 +62,16 @@ func (m *Matter) Validate() error {
 func (m *Matter) Read() error {
 	// first read the .dot env file
 	m.readDotEnv()
-	m.ifTB(func(tb testing.TB) {
+	m.ifTB(func(tb TB) {
 		tb.Logf("Reading file %s for %s/%s", m.filePath(), m.Namespace, m.Name)
 	})
 	front, content, err := readFile(m.filePath())
 	if err != nil {
 		return ErrReadingFile().WithData("file", m.filePath()).WithError(err)
 	}
-	// read content to matter.content
+	// read content to matter.content, without the inline assertions
 	m.front = front
-	m.content = content
+	m.assertions, m.content = parseAssertions(m.filePath(), front, content)
 	return nil
 }
 
@@ -76,9 +89,17 @@ func (m *Matter) parse() ([]byte, error) {
 }
 
 // readDotEnv function will read the .dot env file and
-// store the values in m.dotEnvs only if
+// store the values in m.Vars only if
 // the file is found and read successfully
 func (m *Matter) readDotEnv() {
+	for key, value := range m.dotEnv() {
+		m.Vars[key] = value
+	}
+}
+
+// dotEnv returns the values of the .env file of the namespace,
+// nil when there is none
+func (m *Matter) dotEnv() map[string]string {
 	dotEnvPath := makeFilePath(
 		m.config.BaseDir,
 		m.Namespace,
@@ -86,9 +107,10 @@ func (m *Matter) readDotEnv() {
 		m.config.EnvFileExtension)
 	file, err := openFile(dotEnvPath)
 	if err != nil {
-		return
+		return nil
 	}
 	defer file.Close()
+	values := make(map[string]string)
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
@@ -99,27 +121,53 @@ func (m *Matter) readDotEnv() {
 			continue
 		}
 		if chunks := strings.SplitN(line, "=", 2); len(chunks) == 2 {
-			m.Vars[chunks[0]] = chunks[1]
+			values[chunks[0]] = chunks[1]
+		}
+	}
+	return values
+}
+
+// Directive returns the value of a front matter directive
+// such as "// @name login" or "# @name login"
+func (m *Matter) Directive(key string) (string, bool) {
+	for _, line := range strings.Split(m.front, "\n") {
+		line = strings.TrimSpace(line)
+		line = strings.TrimSpace(strings.TrimLeft(line, "/#"))
+		name, value, _ := strings.Cut(line, " ")
+		if name == "@"+key {
+			return strings.TrimSpace(value), true
 		}
 	}
+	return "", false
 }
 
 func (m *Matter) filePath() string {
 	return makeFilePath(m.config.BaseDir, m.Namespace, m.Name, m.config.FileExtension)
 }
 
-func (m *Matter) ifTB(fn func(tb testing.TB)) {
+func (m *Matter) ifTB(fn func(tb TB)) {
 	if m.tb == nil {
 		return
 	}
 	fn(m.tb)
 }
 
+// Save writes the matter to its file, after running the sanitizers of
+// the config and of WithSanitizers on the content
 func (m *Matter) Save() error {
+	if err := m.sanitize(); err != nil {
+		return err
+	}
+	return m.write()
+}
+
+// write writes front matter and content to the file as they are,
+// inline assertions taken out of the content are put back in it
+func (m *Matter) write() error {
 	filePath := m.filePath()
 	dir := filepath.Dir(filePath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return err
 	}
-	return os.WriteFile(filePath, []byte(m.front+m.content), 0644)
+	return os.WriteFile(filePath, []byte(m.front+m.contentWithAssertions()), 0644)
 }
@@ -0,0 +1,108 @@
+package httpmatter
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Message is the HTTP message of a fixture split into its parts,
+// headers keep the order they have in the file
+type Message struct {
+	// StartLine is the request line or the status line
+	StartLine string
+	Headers   []Header
+	Body      []byte
+	// eol ends the start line and header lines, the one of the parsed
+	// content, \r\n when empty
+	eol string
+}
+
+// Header is a single header line of a Message
+type Header struct {
+	Name  string
+	Value string
+}
+
+// ParseMessage splits fixture content into start line, headers and body,
+// the body is everything after the first empty line. The line ending of
+// the content is kept, so String writes it back the same way
+func ParseMessage(content string) *Message {
+	head, body := content, ""
+	eol := ""
+	crlf, lf := strings.Index(content, "\r\n\r\n"), strings.Index(content, "\n\n")
+	switch {
+	case crlf != -1 && (lf == -1 || crlf < lf):
+		head, body, eol = content[:crlf], content[crlf+4:], "\r\n"
+	case lf != -1:
+		head, body, eol = content[:lf], content[lf+2:], "\n"
+	case strings.Contains(content, "\r\n"):
+		eol = "\r\n"
+	case strings.Contains(content, "\n"):
+		eol = "\n"
+	}
+
+	msg := &Message{Body: []byte(body), eol: eol}
+	for i, line := range strings.Split(head, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if i == 0 {
+			msg.StartLine = line
+			continue
+		}
+		if name, value, ok := strings.Cut(line, ":"); ok {
+			msg.Headers = append(msg.Headers, Header{Name: name, Value: strings.TrimSpace(value)})
+		}
+	}
+	return msg
+}
+
+// Get returns the first value of the header, names are case insensitive
+func (msg *Message) Get(name string) string {
+	for _, header := range msg.Headers {
+		if strings.EqualFold(header.Name, name) {
+			return header.Value
+		}
+	}
+	return ""
+}
+
+// Set replaces every value of the header in place,
+// or adds the header when the message has none
+func (msg *Message) Set(name, value string) {
+	found := false
+	for i, header := range msg.Headers {
+		if strings.EqualFold(header.Name, name) {
+			msg.Headers[i].Value = value
+			found = true
+		}
+	}
+	if !found {
+		msg.Headers = append(msg.Headers, Header{Name: name, Value: value})
+	}
+}
+
+// Del removes every value of the header
+func (msg *Message) Del(name string) {
+	headers := msg.Headers[:0]
+	for _, header := range msg.Headers {
+		if !strings.EqualFold(header.Name, name) {
+			headers = append(headers, header)
+		}
+	}
+	msg.Headers = headers
+}
+
+// String writes the message back in the HTTP wire format
+func (msg *Message) String() string {
+	eol := msg.eol
+	if eol == "" {
+		eol = "\r\n"
+	}
+	out := bytes.Buffer{}
+	out.WriteString(msg.StartLine + eol)
+	for _, header := range msg.Headers {
+		out.WriteString(header.Name + ": " + header.Value + eol)
+	}
+	out.WriteString(eol)
+	out.Write(msg.Body)
+	return out.String()
+}
@@ -2,11 +2,19 @@ package httpmatter
 
 import (
 	"maps"
-	"testing"
+	"net/http"
 )
 
 type Option func(m *Matter) error
 
+// TB is the part of testing.TB used for logging and failing,
+// so fixtures and the mock engine can also be used outside of go test
+type TB interface {
+	Logf(format string, args ...any)
+	Errorf(format string, args ...any)
+	Fatalf(format string, args ...any)
+}
+
 func WithVariables(vars map[string]any) Option {
 	return func(m *Matter) error {
 		if m.Vars == nil {
@@ -17,9 +25,56 @@ func WithVariables(vars map[string]any) Option {
 	}
 }
 
-func WithTB(tb testing.TB) Option {
+func WithTB(tb TB) Option {
 	return func(m *Matter) error {
 		m.tb = tb
 		return nil
 	}
 }
+
+// withExchanges makes earlier exchanges of a session available to references
+// like {{login.response.body.$.token}}
+func withExchanges(exchanges map[string]*exchange) Option {
+	return func(m *Matter) error {
+		m.exchanges = exchanges
+		return nil
+	}
+}
+
+// WithSanitizers adds sanitizers which run on the content before Save,
+// after the ones of the config
+func WithSanitizers(sanitizers ...Sanitizer) Option {
+	return func(m *Matter) error {
+		m.sanitizers = append(m.sanitizers, sanitizers...)
+		return nil
+	}
+}
+
+// WithReverseVars makes Save put back {{name}} placeholders for the values
+// of Vars and of the .env file found in the content, see ReverseVars
+func WithReverseVars() Option {
+	return func(m *Matter) error {
+		m.reverseVars = true
+		return nil
+	}
+}
+
+// WithUpdate gives a response fixture the function fetching the real
+// response, e.g. from the vendor or the handler under test. In update mode
+// (see Updating) the fixture is rewritten when the response differs,
+// otherwise fetch is never called
+func WithUpdate(fetch func() (*http.Response, error)) Option {
+	return func(m *Matter) error {
+		m.fetch = fetch
+		return nil
+	}
+}
+
+// WithCanonical makes Save write the content with Canonicalize,
+// dropping the headers of Config.DropHeaders
+func WithCanonical() Option {
+	return func(m *Matter) error {
+		m.canonical = true
+		return nil
+	}
+}
@@ -0,0 +1,302 @@
+package httpmatter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// placeholderExpr matches placeholders like {{@any}}, {{@regex(^\d+$)}}
+// or {{id=@uuid}}, where id names the captured value
+var placeholderExpr = regexp.MustCompile(`\{\{(?:([a-zA-Z0-9_]+)=)?@([a-z]+)(?:\((.*?)\))?\}\}`)
+
+// Placeholder kinds usable inside request fixtures
+const (
+	PlaceholderAny    = "any"
+	PlaceholderRegex  = "regex"
+	PlaceholderType   = "type"
+	PlaceholderUUID   = "uuid"
+	PlaceholderDate   = "date"
+	PlaceholderIgnore = "ignore"
+)
+
+const (
+	uuidExpr = `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`
+	dateExpr = `\d{4}-\d{2}-\d{2}(?:[T ]\d{2}:\d{2}(?::\d{2}(?:\.\d+)?)?(?:Z|[+-]\d{2}:?\d{2})?)?`
+)
+
+// jsonTypes are the types usable as {{@type(<type>)}} inside JSON bodies
+var jsonTypes = []string{"null", "boolean", "number", "string", "array", "object"}
+
+// Wildcards used by {{@any}} and {{@ignore}} depending on where they are
+const (
+	anySegment = `[^/]*`
+	anyValue   = `.*`
+)
+
+// placeholder is a parsed {{name=@kind(arg)}}
+type placeholder struct {
+	name string
+	kind string
+	arg  string
+}
+
+// escapePlaceholders keeps placeholders as they are when the fixture is
+// executed as a go template. They are quoted, a regex may hold a backtick
+func escapePlaceholders(content string) string {
+	return placeholderExpr.ReplaceAllStringFunc(content, func(s string) string {
+		return "{{" + strconv.Quote(s) + "}}"
+	})
+}
+
+// placeholderQueryEscaper escapes what decoding a query would change
+// or split inside a placeholder, e.g. the + of {{@regex(^\d+$)}}
+var placeholderQueryEscaper = strings.NewReplacer("%", "%25", "+", "%2B", "&", "%26", ";", "%3B")
+
+// fixtureQuery returns the query parameters of a request fixture URL,
+// placeholders are kept as they are written
+func fixtureQuery(u *url.URL) url.Values {
+	raw := placeholderExpr.ReplaceAllStringFunc(u.RawQuery, placeholderQueryEscaper.Replace)
+	values, _ := url.ParseQuery(raw)
+	return values
+}
+
+func hasPlaceholder(s string) bool {
+	return placeholderExpr.MatchString(s)
+}
+
+// parsePlaceholder parses s when it is exactly one placeholder
+func parsePlaceholder(s string) (placeholder, bool) {
+	m := placeholderExpr.FindStringSubmatch(s)
+	if m == nil || m[0] != s {
+		return placeholder{}, false
+	}
+	return placeholder{name: m[1], kind: m[2], arg: m[3]}, true
+}
+
+// expr returns the regular expression matching the placeholder,
+// wildcard is used for any and ignore
+func (p placeholder) expr(wildcard string) (string, error) {
+	switch p.kind {
+	case PlaceholderAny, PlaceholderIgnore:
+		return wildcard, nil
+	case PlaceholderRegex:
+		if _, err := regexp.Compile(p.arg); err != nil {
+			return "", err
+		}
+		return `(?:` + strings.TrimSuffix(strings.TrimPrefix(p.arg, "^"), "$") + `)`, nil
+	case PlaceholderUUID:
+		return uuidExpr, nil
+	case PlaceholderDate:
+		return dateExpr, nil
+	case PlaceholderType:
+		switch p.arg {
+		case "number":
+			return `-?\d+(?:\.\d+)?(?:[eE][+-]?\d+)?`, nil
+		case "boolean":
+			return `true|false`, nil
+		case "string":
+			return `.*`, nil
+		}
+		return "", fmt.Errorf("type %q can only be matched inside JSON bodies", p.arg)
+	}
+	return "", fmt.Errorf("unknown placeholder @%s", p.kind)
+}
+
+// pattern is a fixture value with placeholders compiled
+// into a regular expression
+type pattern struct {
+	re    *regexp.Regexp
+	names []string
+}
+
+var patterns = struct {
+	sync.Mutex
+	cache map[string]*pattern
+}{cache: make(map[string]*pattern)}
+
+// compilePattern compiles a fixture value, literal text must match
+// as is and placeholders match their kind
+func compilePattern(s, wildcard string) (*pattern, error) {
+	key := wildcard + "\x00" + s
+	patterns.Lock()
+	defer patterns.Unlock()
+	if p, ok := patterns.cache[key]; ok {
+		return p, nil
+	}
+
+	expr := strings.Builder{}
+	expr.WriteString("^")
+	var names []string
+	last := 0
+	for _, loc := range placeholderExpr.FindAllStringSubmatchIndex(s, -1) {
+		expr.WriteString(regexp.QuoteMeta(s[last:loc[0]]))
+		p, _ := parsePlaceholder(s[loc[0]:loc[1]])
+		sub, err := p.expr(wildcard)
+		if err != nil {
+			return nil, err
+		}
+		expr.WriteString("(" + sub + ")")
+		names = append(names, p.name)
+		last = loc[1]
+	}
+	expr.WriteString(regexp.QuoteMeta(s[last:]))
+	expr.WriteString("$")
+
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, err
+	}
+	p := &pattern{re: re, names: names}
+	patterns.cache[key] = p
+	return p, nil
+}
+
+// matchValue reports whether actual matches the expected fixture value,
+// named placeholders store what they matched in captures
+func matchValue(expected, actual, wildcard string, captures map[string]string) bool {
+	if !hasPlaceholder(expected) {
+		return expected == actual
+	}
+	p, err := compilePattern(expected, wildcard)
+	if err != nil {
+		return false
+	}
+	m := p.re.FindStringSubmatch(actual)
+	if m == nil {
+		return false
+	}
+	for i, name := range p.names {
+		if name != "" && captures != nil {
+			captures[name] = m[i+1]
+		}
+	}
+	return true
+}
+
+// matchJSON compares decoded JSON values, string values of the expected
+// side may hold placeholders
+func matchJSON(expected, actual any, captures map[string]string) bool {
+	switch e := expected.(type) {
+	case map[string]any:
+		a, ok := actual.(map[string]any)
+		if !ok {
+			return false
+		}
+		for key, value := range e {
+			av, found := a[key]
+			if !found {
+				if p, ok := value.(string); ok && isIgnore(p) {
+					continue
+				}
+				return false
+			}
+			if !matchJSON(value, av, captures) {
+				return false
+			}
+		}
+		for key := range a {
+			if _, found := e[key]; !found {
+				return false
+			}
+		}
+		return true
+	case []any:
+		a, ok := actual.([]any)
+		if !ok || len(a) != len(e) {
+			return false
+		}
+		for i := range e {
+			if !matchJSON(e[i], a[i], captures) {
+				return false
+			}
+		}
+		return true
+	case string:
+		if p, ok := parsePlaceholder(e); ok {
+			return matchJSONPlaceholder(p, actual, captures)
+		}
+		a, ok := actual.(string)
+		if !ok {
+			return false
+		}
+		return matchValue(e, a, anyValue, captures)
+	}
+	return fmt.Sprint(expected) == fmt.Sprint(actual) && jsonType(expected) == jsonType(actual)
+}
+
+// matchJSONPlaceholder matches a JSON value of any type against
+// a placeholder which is the whole expected string
+func matchJSONPlaceholder(p placeholder, actual any, captures map[string]string) bool {
+	switch p.kind {
+	case PlaceholderAny, PlaceholderIgnore:
+	case PlaceholderType:
+		if jsonType(actual) != p.arg {
+			return false
+		}
+	default:
+		s, ok := jsonScalar(actual)
+		if !ok {
+			return false
+		}
+		sub, err := p.expr(anyValue)
+		if err != nil {
+			return false
+		}
+		re, err := regexp.Compile("^(?:" + sub + ")$")
+		if err != nil || !re.MatchString(s) {
+			return false
+		}
+	}
+	if p.name != "" && captures != nil {
+		if s, ok := jsonScalar(actual); ok {
+			captures[p.name] = s
+		} else if b, err := json.Marshal(actual); err == nil {
+			captures[p.name] = string(b)
+		}
+	}
+	return true
+}
+
+func isIgnore(s string) bool {
+	p, ok := parsePlaceholder(s)
+	return ok && p.kind == PlaceholderIgnore
+}
+
+func jsonType(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64, json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func jsonScalar(v any) (string, bool) {
+	switch s := v.(type) {
+	case string:
+		return s, true
+	case float64:
+		return strconv.FormatFloat(s, 'f', -1, 64), true
+	case json.Number:
+		return s.String(), true
+	case bool:
+		return strconv.FormatBool(s), true
+	case nil:
+		return "null", true
+	}
+	return "", false
+}
@@ -0,0 +1,148 @@
+package httpmatter
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchValue(t *testing.T) {
+	should := assert.New(t)
+	captures := map[string]string{}
+	should.True(matchValue("/users/{{id=@regex(^\\d+$)}}/orders", "/users/42/orders", anySegment, captures))
+	should.Equal("42", captures["id"])
+	should.False(matchValue("/users/{{@regex(^\\d+$)}}", "/users/abc", anySegment, nil))
+	should.True(matchValue("/users/{{@any}}", "/users/abc", anySegment, nil))
+	should.False(matchValue("/users/{{@any}}", "/users/abc/orders", anySegment, nil))
+	should.True(matchValue("{{@uuid}}", "0f8fad5b-d9cb-469f-a165-70867728950e", anyValue, nil))
+	should.False(matchValue("{{@uuid}}", "not-a-uuid", anyValue, nil))
+	should.True(matchValue("{{@date}}", "2025-08-18T13:44:32Z", anyValue, nil))
+	should.True(matchValue("{{@date}}", "2025-08-18", anyValue, nil))
+	should.True(matchValue("{{@type(number)}}", "12.5", anyValue, nil))
+	should.True(matchValue("plain", "plain", anyValue, nil))
+	should.False(matchValue("plain", "other", anyValue, nil))
+}
+
+func TestEscapePlaceholders(t *testing.T) {
+	tests := []string{
+		"GET https://example.com/users/{{@any}} HTTP/1.1",
+		"GET https://example.com/search?q={{@regex(^\\d+$)}} HTTP/1.1",
+		"X-Quote: {{@regex(^`[a-z]+`$)}}",
+		`X-Quote: {{id=@regex(^"\w+"$)}}`,
+	}
+	for _, content := range tests {
+		t.Run(content, func(t *testing.T) {
+			tmpl, err := template.New("fixture").Parse(escapePlaceholders(content))
+			require.NoError(t, err)
+			out := strings.Builder{}
+			require.NoError(t, tmpl.Execute(&out, nil))
+			require.Equal(t, content, out.String())
+		})
+	}
+}
+
+func TestMatchJSON(t *testing.T) {
+	should := assert.New(t)
+	decode := func(s string) any {
+		var v any
+		if err := json.Unmarshal([]byte(s), &v); err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	expected := decode(`{"id":"{{id=@any}}","count":"{{@type(number)}}","ts":"{{@ignore}}","tags":["{{@regex(^a.*$)}}"]}`)
+
+	captures := map[string]string{}
+	should.True(matchJSON(expected, decode(`{"id":7,"count":3,"tags":["abc"]}`), captures))
+	should.Equal("7", captures["id"])
+	should.True(matchJSON(expected, decode(`{"id":"x","count":3,"ts":"now","tags":["a"]}`), nil))
+	should.False(matchJSON(expected, decode(`{"count":3,"tags":["a"]}`), nil))
+	should.False(matchJSON(expected, decode(`{"id":1,"count":"3","tags":["a"]}`), nil))
+	should.False(matchJSON(expected, decode(`{"id":1,"count":3,"tags":["b"]}`), nil))
+	should.False(matchJSON(expected, decode(`{"id":1,"count":3,"tags":["a"],"extra":true}`), nil))
+}
+
+func TestHTTPPlaceholders(t *testing.T) {
+	t.Parallel()
+	h := NewHTTP(t, "placeholders").
+		Add("request_get_user", "response_get_user").
+		Respond(nil).
+		Add("request_get_user", "response_get_user").
+		Respond(nil).
+		Add("request_create_event", "response_create_event").
+		Respond(nil)
+	h.Init()
+	defer h.Destroy()
+	client := h.Client()
+
+	must := require.New(t)
+	for _, id := range []string{"123", "456"} {
+		req, err := http.NewRequest(http.MethodGet, "https://example.com/users/"+id, nil)
+		must.NoError(err)
+		req.Header.Set("X-Request-Id", "0f8fad5b-d9cb-469f-a165-70867728950e")
+		resp, err := client.Do(req)
+		must.NoError(err)
+		must.Equal(200, resp.StatusCode)
+		must.Equal(id, h.Capture("userId"))
+	}
+
+	resp, err := client.Post("https://example.com/events", "application/json", strings.NewReader(`{
+		"id": "7c9e6679-7425-40de-944b-e07fc1f90ae7",
+		"name": "signup",
+		"count": 3,
+		"at": "2025-08-18T13:44:32Z",
+		"trace": {"span": 1},
+		"timestamp": 1755524672
+	}`))
+	must.NoError(err)
+	must.Equal(202, resp.StatusCode)
+	must.Equal("7c9e6679-7425-40de-944b-e07fc1f90ae7", h.Capture("eventId"))
+}
+
+func TestHTTPResponderCallsBack(t *testing.T) {
+	var h *HTTP
+	h = NewHTTP(t, "placeholders").
+		Add("request_get_user", "response_get_user").Times(2).
+		Respond(func(req *http.Request, reqm *RequestMatter, respms []*ResponseMatter) *ResponseMatter {
+			if h.Capture("userId") == "123" {
+				// a nested call through the mock must not wait for the outer one
+				nested, err := http.NewRequest(http.MethodGet, "https://example.com/users/456", nil)
+				require.NoError(t, err)
+				nested.Header.Set("X-Request-Id", "0f8fad5b-d9cb-469f-a165-70867728950e")
+				resp, err := h.Client().Do(nested)
+				require.NoError(t, err)
+				resp.Body.Close()
+			}
+			return respms[0]
+		})
+	h.Init()
+	defer h.Destroy()
+
+	done := make(chan error, 1)
+	go func() {
+		req, err := http.NewRequest(http.MethodGet, "https://example.com/users/123", nil)
+		if err != nil {
+			done <- err
+			return
+		}
+		req.Header.Set("X-Request-Id", "0f8fad5b-d9cb-469f-a165-70867728950e")
+		resp, err := h.Client().Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the responder calling back into the mock blocked")
+	}
+	require.Equal(t, "456", h.Capture("userId"))
+}
@@ -0,0 +1,274 @@
+package httpmatter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Recorder modes
+const (
+	// ModeRecord always calls upstream and (over)writes the fixtures
+	ModeRecord = "record"
+	// ModeReplay only serves recorded fixtures and never calls upstream
+	ModeReplay = "replay"
+	// ModeRecordIfMissing serves recorded fixtures and records
+	// the requests which have none
+	ModeRecordIfMissing = "record-if-missing"
+)
+
+// ModeEnv is the environment variable picking the recorder mode
+// when none is set with Mode, replay is used when both are empty
+const ModeEnv = "HTTPMATTER_MODE"
+
+var nonSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Recorder is a http.RoundTripper which records request/response fixture
+// pairs under a namespace and replays them by matching on the request.
+// Fixtures are named request_<slug> and response_<slug>, where the slug
+// is made of the method, host and path, e.g. get_api_example_com_users
+type Recorder struct {
+	t         TB
+	namespace string
+	mode      string
+	upstream  http.RoundTripper
+	// sanitizers run on both fixtures before they are saved
+	sanitizers []Sanitizer
+	// vars are put back as {{name}} placeholders when set
+	vars map[string]any
+
+	once   sync.Once
+	replay *HTTP
+	mu     sync.Mutex
+	names  map[string]int
+}
+
+func NewRecorder(t TB, namespace string) *Recorder {
+	return &Recorder{
+		t:         t,
+		namespace: namespace,
+		mode:      os.Getenv(ModeEnv),
+		upstream:  http.DefaultTransport,
+		names:     make(map[string]int),
+	}
+}
+
+// Mode sets the mode, overriding the ModeEnv environment variable
+func (rec *Recorder) Mode(mode string) *Recorder {
+	rec.mode = mode
+	return rec
+}
+
+// Upstream sets the transport used to record, http.DefaultTransport by default
+func (rec *Recorder) Upstream(upstream http.RoundTripper) *Recorder {
+	rec.upstream = upstream
+	return rec
+}
+
+// Sanitize adds sanitizers which run on every recorded fixture before
+// it is saved, e.g. Sanitize(RedactHeaders(SecretHeaders...))
+func (rec *Recorder) Sanitize(sanitizers ...Sanitizer) *Recorder {
+	rec.sanitizers = append(rec.sanitizers, sanitizers...)
+	return rec
+}
+
+// ReverseVars puts back {{name}} placeholders for the values of vars and
+// of the .env file of the namespace in every recorded fixture, e.g. the
+// real host and token. It can be called with no vars to use the .env file only
+func (rec *Recorder) ReverseVars(vars map[string]any) *Recorder {
+	if rec.vars == nil {
+		rec.vars = make(map[string]any)
+	}
+	maps.Copy(rec.vars, vars)
+	return rec
+}
+
+// Client returns a *http.Client using the recorder
+func (rec *Recorder) Client() *http.Client {
+	return &http.Client{Transport: rec}
+}
+
+func (rec *Recorder) RoundTrip(r *http.Request) (*http.Response, error) {
+	rec.once.Do(rec.init)
+	switch rec.mode {
+	case ModeRecord:
+		return rec.record(r)
+	case ModeRecordIfMissing:
+		resp, err := rec.replay.tryRespond(r, false)
+		if err != nil && ErrNoTrip().Is(err) {
+			return rec.record(r)
+		}
+		return resp, err
+	}
+	return rec.replay.respond(r, false)
+}
+
+// init loads the recorded fixtures for replaying
+func (rec *Recorder) init() {
+	if rec.mode == "" {
+		rec.mode = ModeReplay
+	}
+	switch rec.mode {
+	case ModeRecord, ModeReplay, ModeRecordIfMissing:
+	default:
+		rec.t.Fatalf("unknown recorder mode %q", rec.mode)
+	}
+	rec.replay = NewHTTP(rec.t, rec.namespace)
+	// the reversed placeholders are filled in again when replayed
+	maps.Copy(rec.replay.vars, rec.vars)
+	if rec.mode == ModeRecord {
+		return
+	}
+	routes, err := Routes(rec.namespace)
+	if err != nil && !(rec.mode == ModeRecordIfMissing && ErrReadingFile().Is(err)) {
+		rec.t.Fatalf("error loading recorded fixtures of %s: %v", rec.namespace, err)
+	}
+	for _, route := range routes {
+		rec.replay.addRoute(route).Respond(nil)
+	}
+	rec.replay.Init()
+}
+
+// record sends the request upstream and saves both as fixtures
+func (rec *Recorder) record(r *http.Request) (*http.Response, error) {
+	body, err := peekBody(&r.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := rec.upstream.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	name := rec.name(r)
+
+	// upstream consumed the body, Dump reads it again
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	source := r.Method + " " + r.URL.String()
+	req := NewRequestMatter(rec.namespace, "request_"+name)
+	req.front = recordedFront(name, source, directive("match-body", matchBodyFor(r.Header, body)))
+	if err := req.Dump(r); err != nil {
+		return nil, err
+	}
+	req.sanitizers = rec.sanitizers
+	rec.reverse(req.Matter)
+	if err := req.Save(); err != nil {
+		return nil, err
+	}
+
+	respm := NewResponseMatter(rec.namespace, "response_"+name)
+	if err := respm.Dump(resp); err != nil {
+		return nil, err
+	}
+	respm.front = recordedFront(name, source)
+	respm.sanitizers = rec.sanitizers
+	rec.reverse(respm.Matter)
+	if err := respm.Save(); err != nil {
+		return nil, err
+	}
+	rec.t.Logf("recorded %s %s as %s", r.Method, r.URL, filepath.Join(rec.namespace, name))
+	if rec.mode == ModeRecordIfMissing {
+		rec.replayRecorded(req.Name, respm.Name)
+	}
+	return respm.Clone()
+}
+
+// replayRecorded adds a recorded pair to the fixtures being replayed,
+// like init does for the recorded ones, so the next identical request
+// is not recorded again
+func (rec *Recorder) replayRecorded(request, response string) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.replay.addRoute(Route{Namespace: rec.namespace, Request: request, Responses: []string{response}}).Respond(nil)
+	trip := rec.replay.lastTrip("record")
+	rec.replay.initTrip(trip)
+	rec.replay.mu.Lock()
+	rec.replay.pending = append(rec.replay.pending, trip)
+	rec.replay.mu.Unlock()
+}
+
+// reverse turns on reverse templating of the matter when vars are set
+func (rec *Recorder) reverse(m *Matter) {
+	if rec.vars == nil {
+		return
+	}
+	maps.Copy(m.Vars, rec.vars)
+	m.reverseVars = true
+}
+
+// name returns a readable slug for the request, numbered when
+// the same slug was already recorded by this recorder. When recording
+// missing fixtures, names already on disk are skipped, so the recorded
+// fixtures are never overwritten
+func (rec *Recorder) name(r *http.Request) string {
+	slug := strings.Trim(nonSlug.ReplaceAllString(
+		strings.ToLower(r.Method+"_"+r.URL.Hostname()+"_"+r.URL.Path), "_"), "_")
+	if len(slug) > 80 {
+		slug = strings.TrimRight(slug[:80], "_")
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	for {
+		rec.names[slug]++
+		name := slug
+		if n := rec.names[slug]; n > 1 {
+			name = fmt.Sprintf("%s_%d", slug, n)
+		}
+		if rec.mode != ModeRecordIfMissing || !rec.recorded(name) {
+			return name
+		}
+	}
+}
+
+// recorded reports whether a fixture of the pair exists on disk
+func (rec *Recorder) recorded(name string) bool {
+	for _, prefix := range []string{"request_", "response_"} {
+		path := makeFilePath(config.BaseDir, rec.namespace, prefix+name, config.FileExtension)
+		if _, err := os.Stat(path); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// matchBodyFor picks how a recorded request body is matched on replay
+func matchBodyFor(header http.Header, body []byte) string {
+	switch {
+	case len(body) == 0:
+		return ""
+	case json.Valid(body):
+		return MatchBodyJSON
+	case strings.HasPrefix(header.Get("Content-Type"), "application/x-www-form-urlencoded"):
+		return MatchBodyForm
+	}
+	return MatchBodyExact
+}
+
+// recordedFront returns the front matter of a recorded or dumped fixture,
+// directives are lines like "@match-body json", empty ones are left out
+func recordedFront(name, source string, directives ...string) string {
+	front := "///\n"
+	front += directive("name", name)
+	front += directive("recorded-at", time.Now().UTC().Format(time.RFC3339))
+	front += directive("source", source)
+	for _, d := range directives {
+		front += d
+	}
+	return front + "///\n"
+}
+
+// directive returns a front matter line, or nothing when value is empty
+func directive(key, value string) string {
+	if value == "" {
+		return ""
+	}
+	return "// @" + key + " " + value + "\n"
+}
@@ -0,0 +1,118 @@
+package httpmatter
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorderRecordAndReplay(t *testing.T) {
+	must := require.New(t)
+	namespace := tempNamespace(t)
+
+	hits := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"path":%q,"hit":%d,"body":%q}`, r.URL.Path, hits, body)
+	}))
+	defer upstream.Close()
+
+	call := func(client *http.Client) []string {
+		var bodies []string
+		resp, err := client.Get(upstream.URL + "/users/1?expand=orders")
+		must.NoError(err)
+		b, err := io.ReadAll(resp.Body)
+		must.NoError(err)
+		bodies = append(bodies, string(b))
+
+		resp, err = client.Post(upstream.URL+"/orders", "application/json", strings.NewReader(`{"product":"books"}`))
+		must.NoError(err)
+		b, err = io.ReadAll(resp.Body)
+		must.NoError(err)
+		bodies = append(bodies, string(b))
+
+		resp, err = client.Get(upstream.URL + "/users/1?expand=orders")
+		must.NoError(err)
+		b, err = io.ReadAll(resp.Body)
+		must.NoError(err)
+		return append(bodies, string(b))
+	}
+
+	recorded := call(NewRecorder(t, namespace).Mode(ModeRecord).Client())
+	must.Equal(3, hits)
+	for _, name := range []string{"get_127_0_0_1_users_1", "post_127_0_0_1_orders", "get_127_0_0_1_users_1_2"} {
+		must.FileExists(filepath.Join(config.BaseDir, namespace, "request_"+name+".http"))
+		must.FileExists(filepath.Join(config.BaseDir, namespace, "response_"+name+".http"))
+	}
+
+	t.Setenv(ModeEnv, ModeReplay)
+	replayed := call(NewRecorder(t, namespace).Client())
+	must.Equal(3, hits)
+	must.Equal(recorded, replayed)
+}
+
+func TestRecorderRecordIfMissing(t *testing.T) {
+	must := require.New(t)
+	namespace := tempNamespace(t)
+
+	hits := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		fmt.Fprintf(w, "hit %d", hits)
+	}))
+	defer upstream.Close()
+
+	client := NewRecorder(t, namespace).Mode(ModeRecordIfMissing).Client()
+	_, err := client.Get(upstream.URL + "/a")
+	must.NoError(err)
+	must.Equal(1, hits)
+
+	client = NewRecorder(t, namespace).Mode(ModeRecordIfMissing).Client()
+	resp, err := client.Get(upstream.URL + "/a")
+	must.NoError(err)
+	body, err := io.ReadAll(resp.Body)
+	must.NoError(err)
+	must.Equal("hit 1", string(body))
+	_, err = client.Get(upstream.URL + "/b")
+	must.NoError(err)
+	must.Equal(2, hits)
+}
+
+func TestRecorderRecordIfMissingKeepsRecorded(t *testing.T) {
+	must := require.New(t)
+	namespace := tempNamespace(t)
+
+	hits := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		fmt.Fprintf(w, "page %s", r.URL.Query().Get("page"))
+	}))
+	defer upstream.Close()
+
+	get := func(client *http.Client, page string) string {
+		resp, err := client.Get(upstream.URL + "/users?page=" + page)
+		must.NoError(err)
+		body, err := io.ReadAll(resp.Body)
+		must.NoError(err)
+		return string(body)
+	}
+
+	get(NewRecorder(t, namespace).Mode(ModeRecordIfMissing).Client(), "1")
+	client := NewRecorder(t, namespace).Mode(ModeRecordIfMissing).Client()
+	must.Equal("page 2", get(client, "2"))
+	must.Equal("page 2", get(client, "2"))
+	must.Equal(2, hits)
+
+	replay := NewRecorder(t, namespace).Mode(ModeReplay).Client()
+	must.Equal("page 1", get(replay, "1"))
+	must.Equal("page 2", get(replay, "2"))
+	must.FileExists(filepath.Join(config.BaseDir, namespace, "request_get_127_0_0_1_users_2.http"))
+}
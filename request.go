@@ -2,9 +2,10 @@ package httpmatter
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"net/http"
-	"net/http/httputil"
+	"net/url"
 )
 
 // RequestMatter is a matter that can be used to store request content and error
@@ -40,27 +41,92 @@ func (rm *RequestMatter) BodyString() (string, error) {
 	return string(body), nil
 }
 
+// BodyBytes returns the body, the request stays sendable after inspection
 func (rm *RequestMatter) BodyBytes() ([]byte, error) {
-	body, err := io.ReadAll(rm.Body)
-	if err != nil {
-		return nil, err
-	}
-	// Preserve existing behavior (return the bytes) while also keeping the request
-	// sendable after inspection by resetting the body reader.
-	rm.Body = io.NopCloser(bytes.NewReader(body))
-	return body, nil
+	return peekBody(&rm.Body)
 }
 
+// Dump writes the request as fixture content which ParseRequest reads back
+// into an equivalent request: the request line has the absolute URL,
+// headers keep the order of the current content and the body is kept as is.
+// Existing front matter is kept, otherwise it is generated with the name,
+// the time of the dump and the source of the request
 func (rm *RequestMatter) Dump(req *http.Request) error {
-	b, err := httputil.DumpRequest(req, true)
+	body, err := peekBody(&req.Body)
 	if err != nil {
 		return err
 	}
-	rm.content = string(b)
+	if body != nil {
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+	target := absoluteURL(req)
+
+	out := bytes.Buffer{}
+	fmt.Fprintf(&out, "%s %s HTTP/1.1\r\n", orGet(req.Method), target)
+	for _, name := range headerOrder(rm.content, req.Header) {
+		for _, value := range req.Header.Values(name) {
+			fmt.Fprintf(&out, "%s: %s\r\n", name, value)
+		}
+	}
+	out.WriteString("\r\n")
+	out.Write(body)
+
+	if rm.front == "" {
+		rm.front = recordedFront(rm.Name, orGet(req.Method)+" "+target.String())
+	}
+	rm.content = out.String()
 	rm.Request = req
 	return nil
 }
 
+// absoluteURL returns the URL of the request with scheme and host,
+// which server side requests only have in Host and TLS
+func absoluteURL(req *http.Request) *url.URL {
+	target := *req.URL
+	if target.Host == "" {
+		target.Host = req.Host
+	}
+	if target.Scheme == "" {
+		target.Scheme = "http"
+		if req.TLS != nil {
+			target.Scheme = "https"
+		}
+	}
+	return &target
+}
+
+func orGet(method string) string {
+	if method == "" {
+		return http.MethodGet
+	}
+	return method
+}
+
+// headerOrder returns the names of the headers in the order they have
+// in content, followed by the ones content does not have in sorted order.
+// Host is left out, as the request line has the absolute URL
+func headerOrder(content string, header http.Header) []string {
+	var names []string
+	seen := map[string]bool{"Host": true}
+	if content != "" {
+		for _, h := range ParseMessage(content).Headers {
+			name := http.CanonicalHeaderKey(h.Name)
+			if _, ok := header[name]; ok && !seen[name] {
+				names = append(names, name)
+				seen[name] = true
+			}
+		}
+	}
+	for _, name := range sortedKeys(header) {
+		if !seen[name] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
 func (rm *RequestMatter) Save() error {
 	return rm.Matter.Save()
 }
@@ -0,0 +1,67 @@
+package httpmatter
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestDumpRoundTrip(t *testing.T) {
+	must := require.New(t)
+	namespace := tempNamespace(t)
+
+	// A server side request only has the host in Host
+	r := httptest.NewRequest(http.MethodPost, "/v1/orders?page=2", strings.NewReader(`{"product":"books"}`))
+	r.Host = "api.example.com"
+	r.Header.Set("X-Trace", "abc")
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Add("Accept", "application/json")
+	r.Header.Add("Accept", "text/plain")
+
+	dumped := NewRequestMatter(namespace, "request_order")
+	// Headers keep the order of the content which is already there
+	dumped.content = "POST /v1/orders HTTP/1.1\r\nX-Trace: old\r\nContent-Type: text/plain\r\n\r\n"
+	must.NoError(dumped.Dump(r))
+	must.NoError(dumped.Save())
+
+	must.Equal("POST http://api.example.com/v1/orders?page=2 HTTP/1.1\r\n"+
+		"X-Trace: abc\r\n"+
+		"Content-Type: application/json\r\n"+
+		"Accept: application/json\r\n"+
+		"Accept: text/plain\r\n"+
+		"\r\n"+
+		`{"product":"books"}`, dumped.content)
+	name, _ := dumped.Directive("name")
+	must.Equal("request_order", name)
+	source, _ := dumped.Directive("source")
+	must.Equal("POST http://api.example.com/v1/orders?page=2", source)
+	_, ok := dumped.Directive("recorded-at")
+	must.True(ok)
+
+	// The body can still be read after the dump
+	body, err := io.ReadAll(r.Body)
+	must.NoError(err)
+	must.Equal(`{"product":"books"}`, string(body))
+
+	read := NewRequestMatter(namespace, "request_order")
+	must.NoError(makeMatter(read))
+	must.Equal(http.MethodPost, read.Method)
+	must.Equal("http://api.example.com/v1/orders?page=2", read.URL.String())
+	must.Equal("api.example.com", read.Host)
+	must.Equal([]string{"application/json", "text/plain"}, read.Header.Values("Accept"))
+	must.Equal("abc", read.Header.Get("X-Trace"))
+	readBody, err := read.BodyString()
+	must.NoError(err)
+	must.Equal(`{"product":"books"}`, readBody)
+
+	// Front matter which is already there is kept
+	kept := NewRequestMatter(namespace, "request_order")
+	must.NoError(makeMatter(kept))
+	kept.front = "///\n// @name order\n///\n"
+	must.NoError(kept.Dump(r))
+	must.Equal("///\n// @name order\n///\n", kept.front)
+}
@@ -1,15 +1,20 @@
 package httpmatter
 
 import (
-	"io"
+	"maps"
 	"net/http"
 	"net/http/httputil"
+	"slices"
+	"strings"
 )
 
 // ResponseMatter is a matter that can be used to store response content and error
 type ResponseMatter struct {
 	*Matter
 	*http.Response
+	// fault delays the response or fails it, see the "@error"
+	// and "@delay" directives and ErrorResponse
+	fault fault
 }
 
 func NewResponseMatter(namespace, name string) *ResponseMatter {
@@ -18,7 +23,32 @@ func NewResponseMatter(namespace, name string) *ResponseMatter {
 	}
 }
 
+// Read reads the fixture, in update mode a fixture with WithUpdate
+// is first rewritten from the real response when they differ
+func (rm *ResponseMatter) Read() error {
+	if rm.fetch != nil && Updating() {
+		resp, err := rm.fetch()
+		if err != nil {
+			return ErrUpdatingFixture().WithData("file", rm.filePath()).WithError(err)
+		}
+		if err := rm.update(resp); err != nil {
+			return err
+		}
+	}
+	return rm.Matter.Read()
+}
+
+// Parse renders and parses the fixture. A fixture with an "@error"
+// directive may have no HTTP message, it only fails requests
 func (rm *ResponseMatter) Parse() error {
+	f, err := rm.readFault()
+	if err != nil {
+		return ErrParsingFile().WithData("file", rm.filePath()).WithError(err)
+	}
+	rm.fault = f
+	if f.failing() && strings.TrimSpace(rm.content) == "" {
+		return nil
+	}
 	content, err := rm.parse()
 	if err != nil {
 		return err
@@ -27,10 +57,90 @@ func (rm *ResponseMatter) Parse() error {
 	if err != nil {
 		return err
 	}
+	if _, err := peekBody(&resp.Body); err != nil {
+		return err
+	}
 	rm.Response = resp
 	return nil
 }
 
+// Clone returns a copy of the response with its own header, trailer and
+// body reader, so it can be served or read without touching the fixture.
+// Every call returns a fresh copy
+func (rm *ResponseMatter) Clone() (*http.Response, error) {
+	body, err := peekBody(&rm.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp := *rm.Response
+	resp.Header = rm.Header.Clone()
+	resp.Trailer = rm.Trailer.Clone()
+	resp.Body = newReplayBody(body)
+	if rm.Body == nil {
+		resp.Body = nil
+	}
+	return &resp, nil
+}
+
+// render executes the response fixture again for an incoming request,
+// which the fixture can reference as {{request.<path>}}. Captured params
+// are available as {{request.params.<name>}} and as variables
+func (rm *ResponseMatter) render(req *http.Request, params map[string]string) (*http.Response, error) {
+	m := *rm.Matter
+	m.Vars = maps.Clone(rm.Vars)
+	for name, value := range params {
+		m.Vars[name] = value
+	}
+	m.incoming = req
+	m.params = params
+	content, err := m.parse()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := ParseResponse(content)
+	if err != nil {
+		return nil, err
+	}
+	resp.Request = req
+	return resp, nil
+}
+
+// Match compares the response with the fixture: the status, the headers
+// the fixture declares and the body, where JSON, XML and forms are compared
+// semantically and placeholders like {{@uuid}} match their kind. Headers
+// in ignoreHeaders or in the "// @ignore-headers" directive are skipped.
+// The fixture is rendered with resp.Request, the body of resp can still be
+// read afterwards. The Diff is empty when the response matches
+func (rm *ResponseMatter) Match(resp *http.Response, ignoreHeaders ...string) (Diff, error) {
+	want, err := rm.render(resp.Request, nil)
+	if err != nil {
+		return Diff{}, err
+	}
+	body, err := peekBody(&resp.Body)
+	if err != nil {
+		return Diff{}, err
+	}
+	return compareResponse(want, resp, body, slices.Concat(ignoredHeaders(rm.Matter), ignoreHeaders))
+}
+
+// Assert fails the test with a diff when the response does not match
+// the fixture, see Match. It reports whether the response matches
+func (rm *ResponseMatter) Assert(t TB, resp *http.Response, ignoreHeaders ...string) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+	diff, err := rm.Match(resp, ignoreHeaders...)
+	if err != nil {
+		t.Errorf("error matching response with %s: %v", rm.Name, err)
+		return false
+	}
+	if !diff.Empty() {
+		t.Errorf("response does not match %s:\n%s", rm.Name, diff)
+		return false
+	}
+	return true
+}
+
 func (rm *ResponseMatter) BodyString() (string, error) {
 	body, err := rm.BodyBytes()
 	if err != nil {
@@ -38,21 +148,35 @@ func (rm *ResponseMatter) BodyString() (string, error) {
 	}
 	return string(body), nil
 }
+
+// BodyBytes returns the body as the fixture or dump has it, also after
+// the body was read, and leaves it readable
 func (rm *ResponseMatter) BodyBytes() ([]byte, error) {
-	body, err := io.ReadAll(rm.Body)
-	if err != nil {
-		return nil, err
-	}
-	return body, nil
+	return peekBody(&rm.Body)
 }
 
+// Dump writes the response as fixture content. Chunked bodies are written
+// as they were decoded with a Content-Length, so the fixture parses back
 func (rm *ResponseMatter) Dump(resp *http.Response) error {
-	b, err := httputil.DumpResponse(resp, true)
+	body, err := peekBody(&resp.Body)
+	if err != nil {
+		return err
+	}
+	dumped := *resp
+	dumped.TransferEncoding = nil
+	dumped.ContentLength = int64(len(body))
+	dumped.Body = newReplayBody(body)
+	b, err := httputil.DumpResponse(&dumped, true)
 	if err != nil {
 		return err
 	}
 	rm.content = string(b)
-	rm.Response = resp
+	// the matter keeps its own copy, reading resp does not change it
+	stored := *resp
+	stored.Header = resp.Header.Clone()
+	stored.Trailer = resp.Trailer.Clone()
+	stored.Body = newReplayBody(body)
+	rm.Response = &stored
 	return nil
 }
 
@@ -0,0 +1,197 @@
+package httpmatter
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPResponseTemplating(t *testing.T) {
+	t.Parallel()
+	h := NewHTTP(t, "templating").
+		Add("request_get_order", "response_get_order").
+		Respond(nil).
+		Add("request_get_order", "response_get_order").
+		Respond(nil).
+		Add("request_create_order", "response_create_order").
+		Respond(nil)
+	h.Init()
+	defer h.Destroy()
+	client := h.Client()
+
+	must := require.New(t)
+	for _, id := range []string{"A-1", "B-2"} {
+		req, err := http.NewRequest(http.MethodGet, "https://example.com/orders/"+id+"?page=3", nil)
+		must.NoError(err)
+		req.Header.Set("X-Trace-Id", "trace-"+id)
+		resp, err := client.Do(req)
+		must.NoError(err)
+		body, err := io.ReadAll(resp.Body)
+		must.NoError(err)
+		must.Equal("trace-"+id, resp.Header.Get("X-Trace-Id"))
+		must.JSONEq(`{"id":"`+id+`","param":"`+id+`","page":"3","path":"/orders/`+id+`"}`, string(body))
+	}
+
+	resp, err := client.Post("https://example.com/orders", "application/json",
+		strings.NewReader(`{"customer":{"name":"Jane"}}`))
+	must.NoError(err)
+	body, err := io.ReadAll(resp.Body)
+	must.NoError(err)
+	must.JSONEq(`{"customer":"Jane","method":"POST"}`, string(body))
+}
+
+func TestResponseMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		actual  string
+		ignore  []string
+		diff    Diff
+	}{
+		{
+			name:    "json key order and placeholders",
+			fixture: "HTTP/1.1 200 OK\nContent-Type: application/json\n\n{\"id\": \"{{@uuid}}\", \"tags\": [\"a\", \"b\"], \"at\": \"{{@date}}\"}",
+			actual:  "HTTP/1.1 200 OK\r\nContent-Type: application/json\r\n\r\n{\"at\":\"2025-01-02\",\"tags\":[\"a\",\"b\"],\"id\":\"6ba7b810-9dad-11d1-80b4-00c04fd430c8\"}",
+		},
+		{
+			name:    "json differs",
+			fixture: "HTTP/1.1 200 OK\nContent-Type: application/json\n\n{\"id\": \"{{@uuid}}\", \"name\": \"jane\"}",
+			actual:  "HTTP/1.1 200 OK\r\nContent-Type: application/json\r\n\r\n{\"id\":\"6ba7b810-9dad-11d1-80b4-00c04fd430c8\",\"name\":\"john\"}",
+			diff: Diff{Body: "--- expected\n+++ actual\n  {\n    \"id\": \"6ba7b810-9dad-11d1-80b4-00c04fd430c8\",\n" +
+				"-   \"name\": \"jane\"\n+   \"name\": \"john\"\n  }\n"},
+		},
+		{
+			name: "xml prefixes, attribute order and whitespace",
+			fixture: "HTTP/1.1 200 OK\nContent-Type: application/xml\n\n" +
+				"<s:Envelope xmlns:s=\"urn:soap\">\n  <s:Body>\n    <order id=\"{{@regex(^\\d+$)}}\" state=\"paid\">books</order>\n  </s:Body>\n</s:Envelope>",
+			actual: "HTTP/1.1 200 OK\r\nContent-Type: application/xml\r\n\r\n" +
+				"<soap:Envelope xmlns:soap=\"urn:soap\"><soap:Body><order state=\"paid\" id=\"42\">books</order></soap:Body></soap:Envelope>",
+		},
+		{
+			name:    "xml differs",
+			fixture: "HTTP/1.1 200 OK\nContent-Type: application/xml\n\n<order><state>paid</state></order>",
+			actual:  "HTTP/1.1 200 OK\r\nContent-Type: application/xml\r\n\r\n<order><state>open</state></order>",
+			diff:    Diff{Body: "--- expected\n+++ actual\n  <order>\n-   <state>paid</state>\n+   <state>open</state>\n  </order>\n"},
+		},
+		{
+			name:    "form field order",
+			fixture: "HTTP/1.1 200 OK\nContent-Type: application/x-www-form-urlencoded\n\ntoken={{@any}}&expires=3600",
+			actual:  "HTTP/1.1 200 OK\r\nContent-Type: application/x-www-form-urlencoded\r\n\r\nexpires=3600&token=abc",
+		},
+		{
+			name:    "status and headers",
+			fixture: "HTTP/1.1 201 Created\nLocation: /orders/{{@regex(^\\d+$)}}\nX-Request-Id: fixed\nX-Version: 2\n\n",
+			actual:  "HTTP/1.1 200 OK\r\nLocation: /orders/7\r\nX-Request-Id: random\r\n\r\n",
+			ignore:  []string{"X-Request-Id"},
+			diff: Diff{
+				Status:  "--- expected\n+++ actual\n- 201 Created\n+ 200 OK\n",
+				Headers: "--- expected\n+++ actual\n  Location: /orders/7\n- X-Version: 2\n+ X-Version: <missing>\n",
+			},
+		},
+		{
+			name:    "text placeholders",
+			fixture: "HTTP/1.1 200 OK\nContent-Type: text/plain\n\nhello {{@any}}\n",
+			actual:  "HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\n\r\nhello jane",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			must := require.New(t)
+			expected := NewResponseMatter("match", "response")
+			expected.content = tt.fixture
+			actual, err := ParseResponse([]byte(tt.actual))
+			must.NoError(err)
+
+			diff, err := expected.Match(actual, tt.ignore...)
+			must.NoError(err)
+			must.Equal(tt.diff, diff)
+			must.Equal(tt.diff.Empty(), expected.Assert(&recordingTB{}, actual, tt.ignore...))
+
+			// The body can still be read after matching
+			body, err := io.ReadAll(actual.Body)
+			must.NoError(err)
+			must.Equal(string(ParseMessage(tt.actual).Body), string(body))
+		})
+	}
+}
+
+func TestResponseAssert(t *testing.T) {
+	must := require.New(t)
+	expected := NewResponseMatter("match", "response_user")
+	expected.content = "HTTP/1.1 200 OK\nContent-Type: application/json\n\n{\"name\": \"jane\"}"
+	actual, err := ParseResponse([]byte("HTTP/1.1 404 Not Found\r\nContent-Type: application/json\r\n\r\n{\"name\": \"jane\"}"))
+	must.NoError(err)
+
+	tb := &recordingTB{}
+	must.False(expected.Assert(tb, actual))
+	must.Equal([]string{"response does not match response_user:\n" +
+		"status differs:\n--- expected\n+++ actual\n- 200 OK\n+ 404 Not Found\n"}, tb.errors)
+}
+
+func TestResponseBodyRereadable(t *testing.T) {
+	must := require.New(t)
+	resp, err := Response("basic", "response_with_header")
+	must.NoError(err)
+
+	read, err := io.ReadAll(resp.Body)
+	must.NoError(err)
+	must.Contains(string(read), `"status": "success"`)
+
+	// reading the body directly does not empty it for later inspection
+	body, err := resp.BodyString()
+	must.NoError(err)
+	must.Equal(string(read), body)
+}
+
+func TestResponseClone(t *testing.T) {
+	must := require.New(t)
+	resp, err := Response("basic", "response_with_header")
+	must.NoError(err)
+	want, err := resp.BodyBytes()
+	must.NoError(err)
+
+	first, err := resp.Clone()
+	must.NoError(err)
+	second, err := resp.Clone()
+	must.NoError(err)
+	must.NotSame(first, second)
+
+	first.Header.Set("Content-Type", "text/plain")
+	must.Equal("application/json", resp.Header.Get("Content-Type"))
+	must.Equal("application/json", second.Header.Get("Content-Type"))
+
+	for _, clone := range []*http.Response{first, second} {
+		body, err := io.ReadAll(clone.Body)
+		must.NoError(err)
+		must.Equal(want, body)
+	}
+	body, err := resp.BodyBytes()
+	must.NoError(err)
+	must.Equal(want, body)
+}
+
+func TestHTTPServesInspectedFixture(t *testing.T) {
+	must := require.New(t)
+	h := NewHTTP(t, "matching").
+		Add("request_order_books", "response_order_books").
+		Respond(nil)
+	h.Init()
+	defer h.Destroy()
+
+	fixture := h.trips[0].resps[0]
+	want, err := io.ReadAll(fixture.Body)
+	must.NoError(err)
+	must.NotEmpty(want)
+
+	resp, err := h.Client().Post("https://example.com/api/order", "application/json",
+		strings.NewReader(`{"product":"books","quantity":1}`))
+	must.NoError(err)
+	body, err := io.ReadAll(resp.Body)
+	must.NoError(err)
+	must.Equal(want, body)
+	must.NotSame(fixture.Response, resp)
+}
@@ -0,0 +1,82 @@
+package httpmatter
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RoutesFileName is the file inside a namespace which maps request
+// fixtures to response fixtures, one route per line:
+//
+//	<request> <response> [<response>...]
+//
+// Handler, the recorder and contracts answer with the first response,
+// the others are for responders given the route in Go.
+// Empty lines and # comments are ignored
+const RoutesFileName = "routes.txt"
+
+// Route maps a request fixture to its response fixtures
+type Route struct {
+	Namespace string
+	Request   string
+	Responses []string
+}
+
+// Routes returns the routes of a namespace from its routes file.
+// Without a routes file, routes are found by naming convention:
+// request_<name> is answered by response_<name>
+func Routes(namespace string) ([]Route, error) {
+	dir := filepath.Join(config.BaseDir, namespace)
+	file, err := openFile(filepath.Join(dir, RoutesFileName))
+	if err != nil {
+		return conventionRoutes(namespace, dir)
+	}
+	defer file.Close()
+
+	var routes []Route
+	scanner := bufio.NewScanner(file)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, ErrParsingFile().
+				WithData("file", file.Name()).
+				WithData("line", number).
+				WithData("error", "expected <request> <response> [<response>...]")
+		}
+		routes = append(routes, Route{Namespace: namespace, Request: fields[0], Responses: fields[1:]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, ErrReadingFile().WithData("file", file.Name()).WithError(err)
+	}
+	return routes, nil
+}
+
+func conventionRoutes(namespace, dir string) ([]Route, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, ErrReadingFile().WithData("dir", dir).WithError(err)
+	}
+	var routes []Route
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), config.FileExtension)
+		if entry.IsDir() || !ok {
+			continue
+		}
+		suffix, ok := strings.CutPrefix(name, "request_")
+		if !ok {
+			continue
+		}
+		response := "response_" + suffix
+		if _, err := os.Stat(filepath.Join(dir, response+config.FileExtension)); err != nil {
+			continue
+		}
+		routes = append(routes, Route{Namespace: namespace, Request: name, Responses: []string{response}})
+	}
+	return routes, nil
+}
@@ -0,0 +1,67 @@
+package httpmatter
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoutesFile(t *testing.T) {
+	must := require.New(t)
+	routes, err := Routes("routes")
+	must.NoError(err)
+	must.Equal([]Route{
+		{Namespace: "routes", Request: "request_order_books", Responses: []string{"response_order_books"}},
+		{Namespace: "routes", Request: "request_order_games", Responses: []string{"response_order_games", "response_order_books"}},
+	}, routes)
+}
+
+func TestRoutesConvention(t *testing.T) {
+	must := require.New(t)
+	routes, err := Routes("matching")
+	must.NoError(err)
+	must.Len(routes, 4)
+	must.Equal(Route{Namespace: "matching", Request: "request_order_books", Responses: []string{"response_order_books"}}, routes[0])
+
+	_, err = Routes("does_not_exist")
+	must.True(ErrReadingFile().Is(err))
+}
+
+func TestHandlerServesRoutesRepeatedly(t *testing.T) {
+	handler, err := Handler(t, "routes")
+	must := require.New(t)
+	must.NoError(err)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	for range 2 {
+		resp, err := http.Post(server.URL+"/api/order", "application/json",
+			strings.NewReader(`{"product":"books","quantity":1}`))
+		must.NoError(err)
+		body, err := io.ReadAll(resp.Body)
+		must.NoError(err)
+		must.Equal(201, resp.StatusCode)
+		must.Contains(string(body), "books")
+	}
+}
+
+func TestHandlerUsesTheNamespaceOfEachRoute(t *testing.T) {
+	handler, err := Handler(t, "routes_a", "routes_b")
+	must := require.New(t)
+	must.NoError(err)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	for _, ns := range []string{"a", "b"} {
+		resp, err := http.Get(server.URL + "/" + ns + "/status")
+		must.NoError(err)
+		body, err := io.ReadAll(resp.Body)
+		must.NoError(err)
+		must.Equal(200, resp.StatusCode)
+		must.Equal("service "+ns, strings.TrimSpace(string(body)))
+	}
+}
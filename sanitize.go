@@ -0,0 +1,303 @@
+package httpmatter
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// MinReverseVarLength is the shortest value put back as {{name}}
+// by WithReverseVars and Config.ReverseVars
+const MinReverseVarLength = 4
+
+// Redacted replaces the values removed by RedactHeaders
+const Redacted = "REDACTED"
+
+// SecretHeaders are headers which usually carry credentials,
+// use them with RedactHeaders(SecretHeaders...)
+var SecretHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+	"X-Auth-Token",
+}
+
+// Sanitizer rewrites the message of a fixture before it is saved,
+// e.g. to keep credentials and personal data out of the repository
+type Sanitizer func(msg *Message) error
+
+// sanitize runs the sanitizers of the matter on its content and
+// on the source in its front matter
+func (m *Matter) sanitize() error {
+	sanitizers := slices.Concat(m.config.Sanitizers, m.sanitizers)
+	if m.canonical || m.config.Canonical {
+		sanitizers = append(sanitizers, Canonicalize(m.config.DropHeaders...))
+	}
+	if m.reverseVars || m.config.ReverseVars {
+		sanitizers = append(sanitizers, ReverseVars(m.knownVars(), MinReverseVarLength))
+	}
+	if len(sanitizers) == 0 {
+		return nil
+	}
+	front, err := sanitizeSource(m.front, sanitizers)
+	if err != nil {
+		return ErrSanitizing().WithData("file", m.filePath()).WithError(err)
+	}
+	m.front = front
+	if m.content == "" {
+		return nil
+	}
+	msg := ParseMessage(m.content)
+	for _, sanitize := range sanitizers {
+		if err := sanitize(msg); err != nil {
+			return ErrSanitizing().WithData("file", m.filePath()).WithError(err)
+		}
+	}
+	m.content = msg.String()
+	return nil
+}
+
+// sanitizeSource runs the sanitizers on the "@source" directive of recorded
+// and dumped fixtures, it holds the request line with the full URL
+func sanitizeSource(front string, sanitizers []Sanitizer) (string, error) {
+	lines := strings.SplitAfter(front, "\n")
+	for i, line := range lines {
+		prefix, source, ok := strings.Cut(line, "@source ")
+		if !ok || strings.Trim(prefix, "/# \t") != "" {
+			continue
+		}
+		eol := source[len(strings.TrimRight(source, "\r\n")):]
+		msg := &Message{StartLine: strings.TrimSpace(source)}
+		for _, sanitize := range sanitizers {
+			if err := sanitize(msg); err != nil {
+				return front, err
+			}
+		}
+		lines[i] = prefix + "@source " + msg.StartLine + eol
+	}
+	return strings.Join(lines, ""), nil
+}
+
+// knownVars returns the values of the .env file and of Vars,
+// which win when both have the same name
+func (m *Matter) knownVars() map[string]any {
+	vars := make(map[string]any)
+	for key, value := range m.dotEnv() {
+		vars[key] = value
+	}
+	maps.Copy(vars, m.Vars)
+	return vars
+}
+
+// RedactHeaders replaces the values of the headers with Redacted
+func RedactHeaders(names ...string) Sanitizer {
+	return func(msg *Message) error {
+		for _, name := range names {
+			if msg.Get(name) != "" {
+				msg.Set(name, Redacted)
+			}
+		}
+		return nil
+	}
+}
+
+// ReplaceJSON replaces the values found at the JSON path in the body,
+// e.g. ReplaceJSON("$.user.email", "user@example.com").
+// Bodies which are not JSON or have no value at the path are left as is
+func ReplaceJSON(path string, value any) Sanitizer {
+	return func(msg *Message) error {
+		if !json.Valid(msg.Body) {
+			return nil
+		}
+		steps, err := splitJSONPath(path)
+		if err != nil {
+			return err
+		}
+		if len(steps) == 0 {
+			return ErrSanitizing().WithData("path", path).WithData("reason", "cannot replace the whole body")
+		}
+		var v any
+		decoder := json.NewDecoder(bytes.NewReader(msg.Body))
+		decoder.UseNumber()
+		if err := decoder.Decode(&v); err != nil {
+			return err
+		}
+		if !replaceJSON(v, steps, value) {
+			return nil
+		}
+		body, err := encodeJSONLike(v, msg.Body)
+		if err != nil {
+			return err
+		}
+		msg.Body = body
+		return nil
+	}
+}
+
+// ScrubRegex replaces every match of the expression in the start line,
+// header values and body. The replacement can use $1 like regexp.ReplaceAll
+func ScrubRegex(expr *regexp.Regexp, replacement string) Sanitizer {
+	return func(msg *Message) error {
+		msg.StartLine = expr.ReplaceAllString(msg.StartLine, replacement)
+		for i := range msg.Headers {
+			msg.Headers[i].Value = expr.ReplaceAllString(msg.Headers[i].Value, replacement)
+		}
+		msg.Body = expr.ReplaceAll(msg.Body, []byte(replacement))
+		return nil
+	}
+}
+
+// Placeholder turns every occurrence of the secret back into {{name}},
+// so the fixture reads it from the variables or the .env file when used
+func Placeholder(name, secret string) Sanitizer {
+	return func(msg *Message) error {
+		if secret == "" {
+			return nil
+		}
+		variable := "{{" + name + "}}"
+		msg.StartLine = strings.ReplaceAll(msg.StartLine, secret, variable)
+		for i := range msg.Headers {
+			msg.Headers[i].Value = strings.ReplaceAll(msg.Headers[i].Value, secret, variable)
+		}
+		msg.Body = bytes.ReplaceAll(msg.Body, []byte(secret), []byte(variable))
+		return nil
+	}
+}
+
+// ReverseVars puts back {{name}} for the literal values of the variables,
+// e.g. the real host or token of a recorded fixture. Longer values are
+// replaced first and values shorter than minLength are skipped, so short
+// values like "1" or "id" do not turn up all over the fixture
+func ReverseVars(vars map[string]any, minLength int) Sanitizer {
+	type variable struct{ name, value string }
+	var known []variable
+	for name, v := range vars {
+		if !indexVars.MatchString("{{" + name + "}}") {
+			continue
+		}
+		var value string
+		switch v := v.(type) {
+		case string:
+			value = v
+		case int, int64, float64, json.Number:
+			value = fmt.Sprint(v)
+		default:
+			continue
+		}
+		if len(value) < minLength || len(value) == 0 {
+			continue
+		}
+		known = append(known, variable{name, value})
+	}
+	slices.SortFunc(known, func(a, b variable) int {
+		if n := cmp.Compare(len(b.value), len(a.value)); n != 0 {
+			return n
+		}
+		return cmp.Compare(a.name, b.name)
+	})
+
+	return func(msg *Message) error {
+		if len(known) == 0 {
+			return nil
+		}
+		// One pass over the content, so placeholders already in it and
+		// the ones put back are never replaced again. At the same position
+		// the first alternative wins, which is the longest value
+		alternatives := []string{`\{\{.*?\}\}`}
+		names := make(map[string]string, len(known))
+		for _, v := range known {
+			if _, found := names[v.value]; found {
+				continue
+			}
+			names[v.value] = v.name
+			alternatives = append(alternatives, regexp.QuoteMeta(v.value))
+		}
+		expr := regexp.MustCompile(strings.Join(alternatives, "|"))
+		reverse := func(s string) string {
+			return expr.ReplaceAllStringFunc(s, func(match string) string {
+				if name, ok := names[match]; ok {
+					return "{{" + name + "}}"
+				}
+				return match
+			})
+		}
+		msg.StartLine = reverse(msg.StartLine)
+		for i := range msg.Headers {
+			msg.Headers[i].Value = reverse(msg.Headers[i].Value)
+		}
+		msg.Body = []byte(reverse(string(msg.Body)))
+		return nil
+	}
+}
+
+// replaceJSON sets value at every node the steps lead to,
+// it reports whether anything was replaced
+func replaceJSON(v any, steps []string, value any) bool {
+	step, rest := steps[0], steps[1:]
+	replaced := false
+	switch node := v.(type) {
+	case map[string]any:
+		for key, child := range node {
+			if step != "*" && step != key {
+				continue
+			}
+			if len(rest) == 0 {
+				node[key] = value
+				replaced = true
+			} else if replaceJSON(child, rest, value) {
+				replaced = true
+			}
+		}
+	case []any:
+		for i, child := range node {
+			if step != "*" && !isIndex(step, i, len(node)) {
+				continue
+			}
+			if len(rest) == 0 {
+				node[i] = value
+				replaced = true
+			} else if replaceJSON(child, rest, value) {
+				replaced = true
+			}
+		}
+	}
+	return replaced
+}
+
+// isIndex reports whether step is the index i, negative steps count from the end
+func isIndex(step string, i, length int) bool {
+	n, err := strconv.Atoi(step)
+	if err != nil {
+		return false
+	}
+	if n < 0 {
+		n += length
+	}
+	return n == i
+}
+
+// encodeJSONLike encodes v indented when the original body was indented
+func encodeJSONLike(v any, original []byte) ([]byte, error) {
+	out := bytes.Buffer{}
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	if bytes.Contains(bytes.TrimSpace(original), []byte("\n")) {
+		encoder.SetIndent("", "  ")
+	}
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	body := out.Bytes()
+	if !bytes.HasSuffix(original, []byte("\n")) {
+		body = bytes.TrimSuffix(body, []byte("\n"))
+	}
+	return body, nil
+}
@@ -0,0 +1,210 @@
+package httpmatter
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanitizers(t *testing.T) {
+	content := "POST https://api.example.com/users?api_key=k-123 HTTP/1.1\r\n" +
+		"Authorization: Bearer s3cr3t\r\n" +
+		"Content-Type: application/json\r\n" +
+		"Cookie: session=abc\r\n" +
+		"\r\n" +
+		`{"email":"jane@example.com","cards":[{"number":"4111111111111111"},{"number":"5500000000000004"}],"token":"s3cr3t"}`
+
+	tests := []struct {
+		name       string
+		sanitizer  Sanitizer
+		start      string
+		headers    map[string]string
+		body       string
+		wantsError bool
+		// lf parses the content with \n line endings, which are kept
+		lf bool
+	}{
+		{
+			name:      "redact headers",
+			sanitizer: RedactHeaders(SecretHeaders...),
+			headers:   map[string]string{"Authorization": Redacted, "Cookie": Redacted, "Content-Type": "application/json"},
+		},
+		{
+			name:      "replace json path",
+			sanitizer: ReplaceJSON("$.email", "user@example.com"),
+			body:      `{"cards":[{"number":"4111111111111111"},{"number":"5500000000000004"}],"email":"user@example.com","token":"s3cr3t"}`,
+		},
+		{
+			name:      "replace json wildcard",
+			sanitizer: ReplaceJSON("$.cards[*].number", "XXXX"),
+			body:      `{"cards":[{"number":"XXXX"},{"number":"XXXX"}],"email":"jane@example.com","token":"s3cr3t"}`,
+		},
+		{
+			name:      "replace missing json path",
+			sanitizer: ReplaceJSON("$.password", "XXXX"),
+		},
+		{
+			name:       "replace whole body",
+			sanitizer:  ReplaceJSON("$", "XXXX"),
+			wantsError: true,
+		},
+		{
+			name:      "scrub regex",
+			sanitizer: ScrubRegex(regexp.MustCompile(`api_key=[^&\s]+`), "api_key=XXXX"),
+			start:     "POST https://api.example.com/users?api_key=XXXX HTTP/1.1",
+		},
+		{
+			name:      "placeholder",
+			sanitizer: Placeholder("token", "s3cr3t"),
+			headers:   map[string]string{"Authorization": "Bearer {{token}}"},
+			body:      `{"email":"jane@example.com","cards":[{"number":"4111111111111111"},{"number":"5500000000000004"}],"token":"{{token}}"}`,
+		},
+		{
+			name:      "redact headers lf",
+			sanitizer: RedactHeaders(SecretHeaders...),
+			headers:   map[string]string{"Authorization": Redacted, "Cookie": Redacted},
+			lf:        true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			must := require.New(t)
+			content := content
+			if tt.lf {
+				content = strings.ReplaceAll(content, "\r\n", "\n")
+			}
+			msg := ParseMessage(content)
+			err := tt.sanitizer(msg)
+			if tt.wantsError {
+				must.Error(err)
+				return
+			}
+			must.NoError(err)
+			if tt.start != "" {
+				must.Equal(tt.start, msg.StartLine)
+			}
+			for name, value := range tt.headers {
+				must.Equal(value, msg.Get(name), name)
+			}
+			if tt.body == "" {
+				tt.body = string(ParseMessage(content).Body)
+			}
+			must.Equal(tt.body, string(msg.Body))
+			if tt.lf {
+				must.NotContains(msg.String(), "\r")
+			}
+		})
+	}
+}
+
+func TestRecorderSanitize(t *testing.T) {
+	must := require.New(t)
+	namespace := tempNamespace(t)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=abc")
+		fmt.Fprint(w, `{"email":"jane@example.com","token":"s3cr3t"}`)
+	}))
+	defer upstream.Close()
+
+	client := NewRecorder(t, namespace).Mode(ModeRecord).Sanitize(
+		RedactHeaders(SecretHeaders...),
+		ReplaceJSON("$.email", "user@example.com"),
+		Placeholder("token", "s3cr3t"),
+		ScrubRegex(regexp.MustCompile(`api_key=[^&\s]+`), "api_key="+Redacted),
+	).Client()
+	req, err := http.NewRequest(http.MethodGet, upstream.URL+"/me?api_key=SUPERSECRET", nil)
+	must.NoError(err)
+	req.Header.Set("Authorization", "Bearer s3cr3t")
+	_, err = client.Do(req)
+	must.NoError(err)
+
+	request, err := os.ReadFile(filepath.Join(config.BaseDir, namespace, "request_get_127_0_0_1_me.http"))
+	must.NoError(err)
+	must.Contains(string(request), "Authorization: "+Redacted)
+	must.NotContains(string(request), "s3cr3t")
+	must.NotContains(string(request), "SUPERSECRET")
+	must.Contains(string(request), "// @source GET "+upstream.URL+"/me?api_key="+Redacted+"\n")
+
+	response, err := os.ReadFile(filepath.Join(config.BaseDir, namespace, "response_get_127_0_0_1_me.http"))
+	must.NoError(err)
+	must.Contains(string(response), "Set-Cookie: "+Redacted)
+	must.NotContains(string(response), "SUPERSECRET")
+	must.Contains(string(response), `{"email":"user@example.com","token":"{{token}}"}`)
+
+	// The placeholder is filled in again from the variables when replayed
+	respm := NewResponseMatter(namespace, "response_get_127_0_0_1_me")
+	must.NoError(makeMatter(respm, WithVariables(map[string]any{"token": "s3cr3t"})))
+	body, err := respm.BodyString()
+	must.NoError(err)
+	must.Equal(`{"email":"user@example.com","token":"s3cr3t"}`, body)
+}
+
+func TestReverseVars(t *testing.T) {
+	must := require.New(t)
+	content := "GET https://api.example.com/v1/users/42 HTTP/1.1\r\n" +
+		"Host: api.example.com\r\n" +
+		"Authorization: Bearer s3cr3t-token\r\n" +
+		"X-Trace: {{trace}}\r\n" +
+		"\r\n" +
+		`{"id":42,"host":"https://api.example.com","domain":"example.com"}`
+
+	msg := ParseMessage(content)
+	must.NoError(ReverseVars(map[string]any{
+		"host":   "https://api.example.com",
+		"domain": "example.com",
+		"token":  "s3cr3t-token",
+		"id":     42,
+		"trace":  "trace",
+		"empty":  "",
+		"nested": map[string]any{"a": "example.com"},
+	}, MinReverseVarLength)(msg))
+
+	must.Equal("GET {{host}}/v1/users/42 HTTP/1.1", msg.StartLine)
+	must.Equal("api.{{domain}}", msg.Get("Host"))
+	must.Equal("Bearer {{token}}", msg.Get("Authorization"))
+	must.Equal("{{trace}}", msg.Get("X-Trace"))
+	must.Equal(`{"id":42,"host":"{{host}}","domain":"{{domain}}"}`, string(msg.Body))
+}
+
+func TestRecorderReverseVars(t *testing.T) {
+	must := require.New(t)
+	namespace := tempNamespace(t)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"next":"http://%s/users?page=2"}`, r.Host)
+	}))
+	defer upstream.Close()
+
+	client := NewRecorder(t, namespace).Mode(ModeRecord).
+		ReverseVars(map[string]any{"host": upstream.URL}).Client()
+	_, err := client.Get(upstream.URL + "/users")
+	must.NoError(err)
+
+	request, err := os.ReadFile(filepath.Join(config.BaseDir, namespace, "request_get_127_0_0_1_users.http"))
+	must.NoError(err)
+	must.Contains(string(request), "GET {{host}}/users HTTP/1.1")
+
+	response, err := os.ReadFile(filepath.Join(config.BaseDir, namespace, "response_get_127_0_0_1_users.http"))
+	must.NoError(err)
+	must.Contains(string(response), `{"next":"{{host}}/users?page=2"}`)
+
+	// Replaying fills the placeholders in again from the same vars
+	client = NewRecorder(t, namespace).Mode(ModeReplay).
+		ReverseVars(map[string]any{"host": upstream.URL}).Client()
+	resp, err := client.Get(upstream.URL + "/users")
+	must.NoError(err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	must.NoError(err)
+	must.Equal(fmt.Sprintf(`{"next":"%s/users?page=2"}`, upstream.URL), string(body))
+}
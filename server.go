@@ -0,0 +1,117 @@
+package httpmatter
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"syscall"
+)
+
+// ServerURLVar is the variable holding the URL of the server started by
+// StartServer or StartTLSServer, e.g. {{serverURL}}
+const ServerURLVar = "serverURL"
+
+// StartServer starts a httptest.Server serving the trips, for code under test
+// which cannot take a client or transport (e.g. other processes).
+// Requests match fixtures written against any host, so fixtures for real
+// vendor hosts still match. Start it before Init to use {{serverURL}}
+// in fixtures. The server is closed by Destroy
+func (h *HTTP) StartServer() *httptest.Server {
+	return h.startServer(httptest.NewServer)
+}
+
+// StartTLSServer is StartServer with TLS, use the Client of the
+// returned server to trust its certificate
+func (h *HTTP) StartTLSServer() *httptest.Server {
+	return h.startServer(httptest.NewTLSServer)
+}
+
+func (h *HTTP) startServer(start func(http.Handler) *httptest.Server) *httptest.Server {
+	if h.server != nil {
+		h.t.Fatalf("server already started at %s", h.server.URL)
+	}
+	h.server = start(http.HandlerFunc(h.serveHTTP))
+	h.vars[ServerURLVar] = h.server.URL
+	return h.server
+}
+
+// URL returns the URL of the started server
+func (h *HTTP) URL() string {
+	if h.server == nil {
+		h.t.Fatalf("no server started, use StartServer()")
+	}
+	return h.server.URL
+}
+
+// Setenv sets the environment variable key to the URL of the started
+// server, for the rest of the test when running under go test
+func (h *HTTP) Setenv(key string) *HTTP {
+	if tb, ok := h.t.(interface{ Setenv(key, value string) }); ok {
+		tb.Setenv(key, h.URL())
+	} else if err := os.Setenv(key, h.URL()); err != nil {
+		h.t.Fatalf("error setting %s: %v", key, err)
+	}
+	return h
+}
+
+// serveHTTP turns the incoming request into a client request
+// and writes the response of the matching trip
+func (h *HTTP) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	r.URL.Host = r.Host
+	r.URL.Scheme = "http"
+	if r.TLS != nil {
+		r.URL.Scheme = "https"
+	}
+	resp, err := h.respond(r, true)
+	if errors.As(err, new(injectedError)) && dropConnection(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	defer resp.Body.Close()
+	for name, values := range resp.Header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(w, resp.Body)
+}
+
+// Handler returns a http.Handler serving the routes (see Routes) of the
+// namespaces, every route can be used any number of times and answers with
+// its first response. Each route uses the fixtures of its own namespace,
+// the first route matching a request wins. Matched requests
+// are logged to t, unmatched requests are reported with Errorf and
+// answered with 404
+func Handler(t TB, namespaces ...string) (http.Handler, error) {
+	h := NewHTTP(t, namespaces...)
+	for _, namespace := range namespaces {
+		routes, err := Routes(namespace)
+		if err != nil {
+			return nil, err
+		}
+		for _, route := range routes {
+			h.addRoute(route).Respond(nil).Always()
+		}
+	}
+	h.Init()
+	return http.HandlerFunc(h.serveHTTP), nil
+}
+
+// dropConnection closes the connection without an answer for an injected
+// error, a reset is sent as a TCP RST. It reports whether it could
+func dropConnection(w http.ResponseWriter, err error) bool {
+	conn, _, hijackErr := http.NewResponseController(w).Hijack()
+	if hijackErr != nil {
+		return false
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok && errors.Is(err, syscall.ECONNRESET) {
+		_ = tcp.SetLinger(0)
+	}
+	_ = conn.Close()
+	return true
+}
@@ -0,0 +1,48 @@
+package httpmatter
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPServer(t *testing.T) {
+	h := NewHTTP(t, "server").
+		Add("request_create_order", "response_create_order").
+		Respond(nil)
+	server := h.StartServer()
+	h.Setenv("VENDOR_URL")
+	h.Init()
+	defer h.Destroy()
+
+	must := require.New(t)
+	must.Equal(server.URL, os.Getenv("VENDOR_URL"))
+	resp, err := server.Client().Post(h.URL()+"/v1/orders", "application/json",
+		strings.NewReader(`{"product":"books"}`))
+	must.NoError(err)
+	body, err := io.ReadAll(resp.Body)
+	must.NoError(err)
+	must.Equal(201, resp.StatusCode)
+	must.Equal(server.URL+"/v1/orders/1", resp.Header.Get("Location"))
+	must.JSONEq(`{"id":1}`, string(body))
+}
+
+func TestHTTPTLSServer(t *testing.T) {
+	t.Parallel()
+	h := NewHTTP(t, "server").
+		Add("request_create_order", "response_create_order").
+		Respond(nil)
+	server := h.StartTLSServer()
+	h.Init()
+	defer h.Destroy()
+
+	must := require.New(t)
+	must.True(strings.HasPrefix(server.URL, "https://"))
+	resp, err := server.Client().Post(server.URL+"/v1/orders", "application/json",
+		strings.NewReader(`{"product":"books"}`))
+	must.NoError(err)
+	must.Equal(201, resp.StatusCode)
+}
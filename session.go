@@ -0,0 +1,210 @@
+package httpmatter
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// exchange is a request and the response it got within a session
+type exchange struct {
+	req  *RequestMatter
+	resp *ResponseMatter
+}
+
+// Session runs or mocks named requests in order and records every exchange,
+// so later fixtures can reference earlier ones, e.g.
+// {{login.response.body.$.access_token}}
+type Session struct {
+	t          TB
+	namespaces []string
+	client     *http.Client
+	exchanges  map[string]*exchange
+}
+
+func NewSession(t TB, namespaces ...string) *Session {
+	return &Session{
+		t:          t,
+		namespaces: namespaces,
+		client:     http.DefaultClient,
+		exchanges:  make(map[string]*exchange),
+	}
+}
+
+// WithClient sets the client used to send requests
+func (s *Session) WithClient(client *http.Client) *Session {
+	s.client = client
+	return s
+}
+
+// Do renders the named request, sends it and records the exchange.
+// The response is named like the response fixture of the request by
+// convention (see Routes), so Save writes it there. The inline assertions of the request fixture are checked against
+// the response, failures are reported with Errorf
+func (s *Session) Do(reqname string, opts ...Option) *ResponseMatter {
+	req := s.request(reqname, opts...)
+	resp, err := s.client.Do(req.Request)
+	if err != nil {
+		s.t.Fatalf("error sending request %s: %v", reqname, err)
+	}
+	respm := NewResponseMatter(req.Namespace, responseName(reqname))
+	if err := respm.Dump(resp); err != nil {
+		s.t.Fatalf("error reading response for %s: %v", reqname, err)
+	}
+	s.record(reqname, &exchange{req: req, resp: respm})
+	if err := req.CheckAssertions(respm.Response); err != nil {
+		s.t.Errorf("response of %s fails its assertions:\n%v", reqname, err)
+	}
+	return respm
+}
+
+// Mock renders the named request and records it together with
+// the named response fixture, without sending anything. The response
+// fixture is checked against the inline assertions of the request
+func (s *Session) Mock(reqname, respname string, opts ...Option) *ResponseMatter {
+	req := s.request(reqname, opts...)
+	namespace, err := findNamespace(s.namespaces, respname)
+	if err != nil {
+		s.t.Fatalf("no response found for %s in %v", respname, s.namespaces)
+	}
+	respm := NewResponseMatter(namespace, respname)
+	if err := makeMatter(respm, s.options(opts)...); err != nil {
+		s.t.Fatalf("error creating matter for %s: %v", respname, err)
+	}
+	s.record(reqname, &exchange{req: req, resp: respm})
+	if err := req.CheckAssertions(respm.Response); err != nil {
+		s.t.Errorf("%s fails the assertions of %s:\n%v", respname, reqname, err)
+	}
+	return respm
+}
+
+// Request returns the recorded request of the named exchange
+func (s *Session) Request(name string) *RequestMatter {
+	if ex, ok := s.exchanges[name]; ok {
+		return ex.req
+	}
+	return nil
+}
+
+// Response returns the recorded response of the named exchange
+func (s *Session) Response(name string) *ResponseMatter {
+	if ex, ok := s.exchanges[name]; ok {
+		return ex.resp
+	}
+	return nil
+}
+
+// record stores the exchange under the request name
+// and under its "@name" directive when the fixture has one
+func (s *Session) record(reqname string, ex *exchange) {
+	s.exchanges[reqname] = ex
+	if name, ok := ex.req.Directive("name"); ok && name != "" {
+		s.exchanges[name] = ex
+	}
+}
+
+func (s *Session) request(name string, opts ...Option) *RequestMatter {
+	namespace, err := findNamespace(s.namespaces, name)
+	if err != nil {
+		s.t.Fatalf("no request found for %s in %v", name, s.namespaces)
+	}
+	req := NewRequestMatter(namespace, name)
+	if err := makeMatter(req, s.options(opts)...); err != nil {
+		s.t.Fatalf("error creating matter for %s: %v", name, err)
+	}
+	return req
+}
+
+func (s *Session) options(opts []Option) []Option {
+	return append([]Option{WithTB(s.t), withExchanges(s.exchanges)}, opts...)
+}
+
+// ref resolves a reference like <name>.response.body.$.token
+// against the exchanges known to the matter, or request.<path>
+// against the incoming request a response is rendered for
+func (m *Matter) ref(path string) (any, error) {
+	name, rest, _ := strings.Cut(path, ".")
+	if name == "request" {
+		return m.lookupIncoming(rest)
+	}
+	ex, ok := m.exchanges[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown reference %q", path)
+	}
+	side, rest, _ := strings.Cut(rest, ".")
+	switch side {
+	case "request":
+		return lookupRequest(ex.req.Request, rest)
+	case "response":
+		return lookupResponse(ex.resp.Response, rest)
+	}
+	return nil, fmt.Errorf("unknown reference %q", path)
+}
+
+// lookupIncoming resolves params.<name> from the captured placeholders
+// and everything else from the incoming request. Without an incoming
+// request, e.g. when the fixture is loaded up front, it resolves to ""
+func (m *Matter) lookupIncoming(path string) (any, error) {
+	if m.incoming == nil {
+		return "", nil
+	}
+	if field, rest, _ := strings.Cut(path, "."); field == "params" {
+		return m.params[rest], nil
+	}
+	return lookupRequest(m.incoming, path)
+}
+
+// lookupRequest resolves method, url, path, query.<name>,
+// headers.<name> and body[.<query>]
+func lookupRequest(req *http.Request, path string) (any, error) {
+	switch field, rest, _ := strings.Cut(path, "."); field {
+	case "method":
+		return req.Method, nil
+	case "url":
+		return req.URL.String(), nil
+	case "path":
+		return req.URL.Path, nil
+	case "query":
+		return req.URL.Query().Get(rest), nil
+	case "headers":
+		return req.Header.Get(rest), nil
+	case "body":
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			return lookupBody(&body, rest)
+		}
+		return lookupBody(&req.Body, rest)
+	}
+	return nil, fmt.Errorf("unknown request field %q", path)
+}
+
+// lookupResponse resolves status, headers.<name> and body[.<query>]
+func lookupResponse(resp *http.Response, path string) (any, error) {
+	switch field, rest, _ := strings.Cut(path, "."); field {
+	case "status":
+		return resp.StatusCode, nil
+	case "headers":
+		return resp.Header.Get(rest), nil
+	case "body":
+		return lookupBody(&resp.Body, rest)
+	}
+	return nil, fmt.Errorf("unknown response field %q", path)
+}
+
+func lookupBody(body *io.ReadCloser, expr string) (any, error) {
+	b, err := peekBody(body)
+	if err != nil {
+		return nil, err
+	}
+	return queryBody(b, expr)
+}
+
+// responseName returns the response fixture name of a request fixture,
+// request_<name> is answered by response_<name>
+func responseName(reqname string) string {
+	return "response_" + strings.TrimPrefix(reqname, "request_")
+}
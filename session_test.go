@@ -0,0 +1,77 @@
+package httpmatter
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionDoChainsResponses(t *testing.T) {
+	must := require.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "live-token"})
+		case "/profile":
+			w.Header().Set("X-Seen-Authorization", r.Header.Get("Authorization"))
+			w.Header().Set("X-Seen-User", r.Header.Get("X-Login-User"))
+		}
+	}))
+	defer server.Close()
+
+	vars := WithVariables(map[string]any{"baseURL": server.URL, "password": "secret"})
+	session := NewSession(t, "session")
+	login := session.Do("request_login", vars)
+	must.Equal(200, login.StatusCode)
+
+	profile := session.Do("request_profile", vars)
+	must.Equal("Bearer live-token", profile.Header.Get("X-Seen-Authorization"))
+	must.Equal("john", profile.Header.Get("X-Seen-User"))
+	must.Equal("200", session.Request("request_profile").Header.Get("X-Login-Status"))
+}
+
+func TestSessionMockChainsResponses(t *testing.T) {
+	must := require.New(t)
+	vars := WithVariables(map[string]any{"baseURL": "https://example.com", "password": "secret"})
+	session := NewSession(t, "session")
+	session.Mock("request_login", "response_login", vars)
+
+	// Mock the profile call too, only the rendered request matters here
+	session.Mock("request_profile", "response_login", vars)
+	profile := session.Request("request_profile")
+	must.Equal("Bearer mocked-token", profile.Header.Get("Authorization"))
+	must.Equal("201", profile.Header.Get("X-Login-Status"))
+	must.Nil(session.Response("unknown"))
+}
+
+func TestSessionDoSavesResponseSeparately(t *testing.T) {
+	must := require.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "live-token"})
+	}))
+	defer server.Close()
+
+	namespace := tempNamespace(t)
+	request, err := os.ReadFile(filepath.Join(config.BaseDir, "session", "request_login.http"))
+	must.NoError(err)
+	must.NoError(os.WriteFile(filepath.Join(config.BaseDir, namespace, "request_login.http"), request, 0644))
+
+	vars := WithVariables(map[string]any{"baseURL": server.URL, "password": "secret"})
+	login := NewSession(t, namespace).Do("request_login", vars)
+	must.Equal("response_login", login.Name)
+	must.NoError(login.Save())
+
+	kept, err := os.ReadFile(filepath.Join(config.BaseDir, namespace, "request_login.http"))
+	must.NoError(err)
+	must.Equal(string(request), string(kept))
+	saved, err := os.ReadFile(filepath.Join(config.BaseDir, namespace, "response_login.http"))
+	must.NoError(err)
+	must.Contains(string(saved), "live-token")
+}
@@ -7,17 +7,24 @@ import (
 )
 
 var indexVars = regexp.MustCompile(`\{\{([a-zA-Z0-9_]+)\}\}`)
+var refVars = regexp.MustCompile(`\{\{([a-zA-Z_][a-zA-Z0-9_]*\.[^{}]+?)\}\}`)
 
 // convertToGoTemplate http front matter is bit different from go template
 // these are special cases this function will cover
 // 1. {{<key>}} to {{index .Vars "<key>"}}
+// 2. {{<name>.<path>}} to {{ref `<name>.<path>`}}
+// 3. {{@<placeholder>}} is kept as it is for matching
 func convertToGoTemplate(content string) string {
-	out := indexVars.ReplaceAllString(content, `{{ index .Vars "$1" }}`)
+	out := escapePlaceholders(content)
+	out = indexVars.ReplaceAllString(out, `{{ index .Vars "$1" }}`)
+	out = refVars.ReplaceAllString(out, "{{ ref `$1` }}")
 	return out
 }
 
 func executeTemplate(content string, matter *Matter) ([]byte, error) {
-	tmpl, err := template.New(matter.filePath()).Parse(content)
+	tmpl, err := template.New(matter.filePath()).
+		Funcs(template.FuncMap{"ref": matter.ref}).
+		Parse(content)
 	if err != nil {
 		return nil, ErrParsingTemplate().WithError(err)
 	}
@@ -41,3 +41,12 @@ func TestExecuteTemplate(t *testing.T) {
 	not a 123,
 	not a 2021-01-01`), out)
 }
+
+func TestConvertToGoTemplateRefs(t *testing.T) {
+	must := require.New(t)
+	content := `Bearer {{login.response.body.$.access_token}}
+	{{ index .Vars "who" }} {{host}}`
+	out := convertToGoTemplate(content)
+	must.Equal("Bearer {{ ref `login.response.body.$.access_token` }}\n\t"+
+		`{{ index .Vars "who" }} {{ index .Vars "host" }}`, out)
+}
@@ -0,0 +1,191 @@
+package httpmatter
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// UpdateEnv is the environment variable turning on update mode,
+// like the -httpmatter.update flag of go test
+const UpdateEnv = "HTTPMATTER_UPDATE"
+
+var updateFlag = flag.Bool("httpmatter.update", false,
+	"rewrite response fixtures which differ from the real upstream")
+
+// volatileResponseHeaders change on every response,
+// a fixture is not rewritten when only they differ
+var volatileResponseHeaders = []string{
+	"Date",
+	"Content-Length",
+	"Transfer-Encoding",
+	"Connection",
+	"Keep-Alive",
+}
+
+var updated = struct {
+	sync.Mutex
+	files []string
+}{}
+
+// Updating reports whether fixtures are rewritten from the real responses,
+// with go test -httpmatter.update or HTTPMATTER_UPDATE=1.
+// Nothing is ever fetched nor written when it is off
+func Updating() bool {
+	if *updateFlag {
+		return true
+	}
+	on, _ := strconv.ParseBool(os.Getenv(UpdateEnv))
+	return on
+}
+
+// UpdatedFixtures returns the files rewritten so far in update mode
+func UpdatedFixtures() []string {
+	updated.Lock()
+	defer updated.Unlock()
+	return slices.Clone(updated.files)
+}
+
+// WriteUpdateSummary writes the files rewritten in update mode,
+// e.g. from TestMain after m.Run()
+func WriteUpdateSummary(w io.Writer) {
+	if !Updating() {
+		return
+	}
+	files := UpdatedFixtures()
+	if len(files) == 0 {
+		fmt.Fprintln(w, "httpmatter: no fixtures changed")
+		return
+	}
+	fmt.Fprintf(w, "httpmatter: %d fixtures changed:\n", len(files))
+	for _, file := range files {
+		fmt.Fprintf(w, "  %s\n", file)
+	}
+}
+
+// update rewrites the fixture with the real response when they differ.
+// The dump goes through the sanitizers and known vars are put back as
+// {{name}}, so a response which did not change is left as it is
+func (rm *ResponseMatter) update(resp *http.Response) error {
+	path := rm.filePath()
+	front, content, err := readFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return ErrUpdatingFixture().WithData("file", path).WithError(err)
+	}
+	if err := rm.Dump(resp); err != nil {
+		return ErrUpdatingFixture().WithData("file", path).WithError(err)
+	}
+	rm.front = front
+	rm.assertions, content = parseAssertions(path, front, content)
+	if rm.front == "" && resp.Request != nil {
+		rm.front = recordedFront(rm.Name, resp.Request.Method+" "+resp.Request.URL.String())
+	}
+	reverse := rm.reverseVars
+	rm.reverseVars = true
+	err = rm.sanitize()
+	rm.reverseVars = reverse
+	if err != nil {
+		return err
+	}
+	if content != "" && sameMessage(content, rm.content) {
+		rm.content = content
+		return nil
+	}
+	if err := rm.write(); err != nil {
+		return ErrUpdatingFixture().WithData("file", path).WithError(err)
+	}
+	updated.Lock()
+	updated.files = append(updated.files, path)
+	updated.Unlock()
+	rm.ifTB(func(tb TB) {
+		tb.Logf("updated %s", path)
+	})
+	return nil
+}
+
+// sameMessage compares the status or request line, the body and the
+// headers of two fixture contents, volatile headers are left out
+func sameMessage(a, b string) bool {
+	ma, mb := ParseMessage(a), ParseMessage(b)
+	if ma.StartLine != mb.StartLine || string(ma.Body) != string(mb.Body) {
+		return false
+	}
+	headers := func(msg *Message) []string {
+		var out []string
+		for _, h := range msg.Headers {
+			name := http.CanonicalHeaderKey(h.Name)
+			if !slices.Contains(volatileResponseHeaders, name) {
+				out = append(out, name+": "+h.Value)
+			}
+		}
+		slices.Sort(out)
+		return out
+	}
+	return slices.Equal(headers(ma), headers(mb))
+}
+
+// Upstream sets where requests go in update mode, the real network
+// (http.DefaultTransport as it was before any mocking) by default.
+// Use HandlerTransport for the handler under test
+func (h *HTTP) Upstream(upstream http.RoundTripper) *HTTP {
+	h.upstream = upstream
+	return h
+}
+
+// HandlerTransport is a http.RoundTripper which serves requests
+// with the handler, without a network round trip
+func HandlerTransport(handler http.Handler) http.RoundTripper {
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		resp := rec.Result()
+		resp.Request = r
+		return resp, nil
+	})
+}
+
+// update sends the request to the upstream and rewrites the chosen
+// response fixture when the real response differs. In server mode the
+// request goes to the host of the request fixture
+func (h *HTTP) update(r *http.Request, body []byte, trip *trip, chosen *ResponseMatter, anyHost bool) (*http.Response, error) {
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	out.Body = io.NopCloser(strings.NewReader(string(body)))
+	out.ContentLength = int64(len(body))
+	if anyHost {
+		out.URL.Scheme, out.URL.Host, out.Host = trip.req.URL.Scheme, trip.req.URL.Host, ""
+	}
+	resp, err := h.upstream.RoundTrip(out)
+	if err != nil {
+		h.t.Errorf("error updating %s from %s: %v", chosen.Name, out.URL, err)
+		return nil, err
+	}
+	// only rewriting the fixture is serialized, the round trip above
+	// may call back into the mock
+	h.updating.Lock()
+	defer h.updating.Unlock()
+	// a copy is updated and then replaces the fixture of the trip,
+	// so responders and renders running meanwhile never see it change
+	replacement := *chosen
+	m := *chosen.Matter
+	replacement.Matter = &m
+	if err := replacement.update(resp); err != nil {
+		h.t.Errorf("error updating %s: %v", chosen.Name, err)
+		return nil, err
+	}
+	h.mu.Lock()
+	if i := slices.Index(trip.resps, chosen); i != -1 {
+		trip.resps[i] = &replacement
+	}
+	h.mu.Unlock()
+	return replacement.Clone()
+}
@@ -0,0 +1,139 @@
+package httpmatter
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// updateNamespace creates a temporary namespace with the given fixtures,
+// names starting with a dot (e.g. .env) are written as they are
+func updateNamespace(t *testing.T, fixtures map[string]string) string {
+	namespace := tempNamespace(t)
+	dir := filepath.Join(config.BaseDir, namespace)
+	for name, content := range fixtures {
+		if !strings.HasPrefix(name, ".") {
+			name += config.FileExtension
+		}
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	return namespace
+}
+
+func fetchBody(status int, body string) func() (*http.Response, error) {
+	return func() (*http.Response, error) {
+		return &http.Response{
+			StatusCode: status,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header: http.Header{
+				"Content-Type": {"application/json"},
+				"Date":         {time.Now().UTC().Format(http.TimeFormat)},
+			},
+			Body:          io.NopCloser(strings.NewReader(body)),
+			ContentLength: int64(len(body)),
+		}, nil
+	}
+}
+
+func TestResponseUpdate(t *testing.T) {
+	must := require.New(t)
+	original := "///\n// @name user\n///\n" +
+		"HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nDate: Mon, 02 Jan 2006 15:04:05 GMT\r\n\r\n" +
+		`{"name":"jane"}`
+	namespace := updateNamespace(t, map[string]string{"response_user": original})
+	path := filepath.Join(config.BaseDir, namespace, "response_user.http")
+
+	// Nothing is fetched nor written when update mode is off
+	fetched := false
+	_, err := Response(namespace, "response_user", WithUpdate(func() (*http.Response, error) {
+		fetched = true
+		return fetchBody(http.StatusOK, `{"name":"john"}`)()
+	}))
+	must.NoError(err)
+	must.False(fetched)
+	b, err := os.ReadFile(path)
+	must.NoError(err)
+	must.Equal(original, string(b))
+
+	t.Setenv(UpdateEnv, "1")
+
+	// Only the date differs, the fixture is left as it is
+	_, err = Response(namespace, "response_user", WithUpdate(fetchBody(http.StatusOK, `{"name":"jane"}`)))
+	must.NoError(err)
+	b, err = os.ReadFile(path)
+	must.NoError(err)
+	must.Equal(original, string(b))
+	must.NotContains(UpdatedFixtures(), path)
+
+	// The body differs, the fixture is rewritten and keeps its front matter
+	resp, err := Response(namespace, "response_user", WithUpdate(fetchBody(http.StatusCreated, `{"name":"john"}`)))
+	must.NoError(err)
+	must.Equal(http.StatusCreated, resp.StatusCode)
+	body, err := resp.BodyString()
+	must.NoError(err)
+	must.Equal(`{"name":"john"}`, body)
+	b, err = os.ReadFile(path)
+	must.NoError(err)
+	must.True(strings.HasPrefix(string(b), "///\n// @name user\n///\n"))
+	must.Contains(UpdatedFixtures(), path)
+
+	summary := strings.Builder{}
+	WriteUpdateSummary(&summary)
+	must.Contains(summary.String(), path)
+}
+
+func TestHTTPUpdate(t *testing.T) {
+	must := require.New(t)
+	namespace := updateNamespace(t, map[string]string{
+		config.EnvFileName + config.EnvFileExtension: "host=https://api.vendor.com",
+		"request_orders":  "GET {{host}}/v1/orders HTTP/1.1\r\n\r\n",
+		"response_orders": "HTTP/1.1 200 OK\r\nContent-Type: application/json\r\n\r\n[]",
+	})
+	t.Setenv(UpdateEnv, "1")
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `[{"id":1,"next":"https://%s/v1/orders?page=2"}]`, r.Host)
+	})
+	var seen []*ResponseMatter
+	tb := &recordingTB{}
+	h := NewHTTP(tb, namespace).Upstream(HandlerTransport(handler)).
+		Add("request_orders", "response_orders").Times(2).
+		Respond(func(req *http.Request, reqm *RequestMatter, respms []*ResponseMatter) *ResponseMatter {
+			seen = append(seen, respms[0])
+			return respms[0]
+		})
+	h.Init()
+
+	for range 2 {
+		resp, err := h.Client().Get("https://api.vendor.com/v1/orders")
+		must.NoError(err)
+		body, err := io.ReadAll(resp.Body)
+		must.NoError(err)
+		must.Equal(`[{"id":1,"next":"https://api.vendor.com/v1/orders?page=2"}]`, string(body))
+	}
+	h.Destroy()
+	must.Empty(tb.errors)
+
+	// The host from the .env file is put back as {{host}}
+	path := filepath.Join(config.BaseDir, namespace, "response_orders.http")
+	b, err := os.ReadFile(path)
+	must.NoError(err)
+	must.Contains(string(b), `[{"id":1,"next":"{{host}}/v1/orders?page=2"}]`)
+	must.Contains(tb.logs, "updated "+path)
+
+	// The update replaced the fixture of the trip instead of changing it
+	must.Len(seen, 2)
+	must.NotSame(seen[0], seen[1])
+	must.Contains(seen[0].content, "[]")
+	must.Contains(seen[1].content, "{{host}}/v1/orders?page=2")
+}
@@ -0,0 +1,276 @@
+package httpmatter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// volatileRequestHeaders are never verified, they are derived from the body
+// or set by the transport
+var volatileRequestHeaders = []string{"Content-Length", "User-Agent", "Accept-Encoding"}
+
+// verifyRequest compares an outgoing request with its request fixture.
+// Only headers declared by the fixture are checked, headers in ignore
+// are skipped and the body is compared as by diffBody. It returns a
+// readable report, or "" when both agree
+func verifyRequest(expected *RequestMatter, actual *http.Request, body []byte, ignore []string, query queryMatch) string {
+	report := strings.Builder{}
+	if diff := diffHeaders(expected.Header, actual.Header, slices.Concat(ignore, volatileRequestHeaders)); diff != "" {
+		report.WriteString("headers differ:\n" + diff)
+	}
+
+	wantQuery, gotQuery := query.without(fixtureQuery(expected.URL)), query.without(actual.URL.Query())
+	if matchQuery(wantQuery, gotQuery, query, nil) {
+		wantQuery = gotQuery
+	}
+	if diff := diffLines(formatQuery(wantQuery), formatQuery(gotQuery)); diff != "" {
+		report.WriteString("query differs:\n" + diff)
+	}
+
+	want, err := expected.BodyBytes()
+	if err != nil {
+		report.WriteString(fmt.Sprintf("error reading expected body: %v\n", err))
+	}
+	if diff := diffBody(expected.Header.Get("Content-Type"), want, body); diff != "" {
+		report.WriteString("body differs:\n" + diff)
+	}
+	return report.String()
+}
+
+// Diff is the difference between a response and its response fixture,
+// each part is a unified diff which is empty when that part matches
+type Diff struct {
+	Status  string
+	Headers string
+	Body    string
+}
+
+// Empty reports whether the response matches the fixture
+func (d Diff) Empty() bool {
+	return d.Status == "" && d.Headers == "" && d.Body == ""
+}
+
+// String returns a readable report of every part which differs
+func (d Diff) String() string {
+	report := strings.Builder{}
+	if d.Status != "" {
+		report.WriteString("status differs:\n" + d.Status)
+	}
+	if d.Headers != "" {
+		report.WriteString("headers differ:\n" + d.Headers)
+	}
+	if d.Body != "" {
+		report.WriteString("body differs:\n" + d.Body)
+	}
+	return report.String()
+}
+
+// compareResponse compares a response with its response fixture: the
+// status, the headers declared by the fixture except the ones in ignore,
+// and the body (see diffBody)
+func compareResponse(expected *http.Response, actual *http.Response, body []byte, ignore []string) (Diff, error) {
+	d := Diff{}
+	if expected.StatusCode != actual.StatusCode {
+		d.Status = diffLines(statusText(expected), statusText(actual))
+	}
+	d.Headers = diffHeaders(expected.Header, actual.Header, slices.Concat(ignore, volatileResponseHeaders))
+	want, err := peekBody(&expected.Body)
+	if err != nil {
+		return d, err
+	}
+	d.Body = diffBody(expected.Header.Get("Content-Type"), want, body)
+	return d, nil
+}
+
+// diffBody compares bodies by the expected content type: JSON and XML
+// semantically, forms field by field and other bodies as text, where
+// placeholders match their kind. It returns a diff of the formatted
+// bodies, or "" when they match
+func diffBody(contentType string, want, got []byte) string {
+	format := formatBody
+	var matches bool
+	switch bodyKind(contentType, want) {
+	case MatchBodyJSON:
+		matches = equalJSON(want, got, nil)
+		want = alignBody(want, got)
+	case MatchBodyXML:
+		matches = equalXML(want, got, nil)
+		format = formatXML
+	case MatchBodyForm:
+		matches = equalForm(want, got, nil)
+		format = formatForm
+	default:
+		matches = matchValue(string(trimLineBreaks(want)), string(trimLineBreaks(got)), anyValue, nil)
+	}
+	if matches {
+		return ""
+	}
+	return diffLines(format(want), format(got))
+}
+
+// bodyKind picks how a body is compared, by content type or by the
+// body itself when there is no content type
+func bodyKind(contentType string, body []byte) string {
+	contentType = strings.ToLower(contentType)
+	switch {
+	case strings.Contains(contentType, "json"):
+		return MatchBodyJSON
+	case strings.Contains(contentType, "xml"):
+		return MatchBodyXML
+	case strings.HasPrefix(contentType, "application/x-www-form-urlencoded"):
+		return MatchBodyForm
+	case contentType != "":
+		return MatchBodyExact
+	case json.Valid(body):
+		return MatchBodyJSON
+	case bytes.HasPrefix(bytes.TrimSpace(body), []byte("<")):
+		return MatchBodyXML
+	}
+	return MatchBodyExact
+}
+
+// alignBody returns the expected body as it should be diffed: the actual
+// body when both match, otherwise JSON placeholders which match are
+// replaced with the actual values, so only real differences are reported
+func alignBody(want, got []byte) []byte {
+	if equalJSON(want, got, nil) ||
+		matchValue(string(trimLineBreaks(want)), string(trimLineBreaks(got)), anyValue, nil) {
+		return got
+	}
+	var e, a any
+	if json.Unmarshal(want, &e) != nil || json.Unmarshal(got, &a) != nil {
+		return want
+	}
+	aligned, err := json.Marshal(alignJSON(e, a))
+	if err != nil {
+		return want
+	}
+	return aligned
+}
+
+// alignJSON replaces the expected values which match the actual ones
+// through a placeholder with the actual values
+func alignJSON(expected, actual any) any {
+	switch e := expected.(type) {
+	case map[string]any:
+		a, ok := actual.(map[string]any)
+		if !ok {
+			return expected
+		}
+		out := make(map[string]any, len(e))
+		for key, value := range e {
+			if av, found := a[key]; found {
+				out[key] = alignJSON(value, av)
+			} else {
+				out[key] = value
+			}
+		}
+		return out
+	case []any:
+		a, ok := actual.([]any)
+		if !ok {
+			return expected
+		}
+		out := make([]any, len(e))
+		for i, value := range e {
+			out[i] = value
+			if i < len(a) {
+				out[i] = alignJSON(value, a[i])
+			}
+		}
+		return out
+	case string:
+		if p, ok := parsePlaceholder(e); ok && matchJSONPlaceholder(p, actual, nil) {
+			return actual
+		}
+		if a, ok := actual.(string); ok && matchValue(e, a, anyValue, nil) {
+			return actual
+		}
+	}
+	return expected
+}
+
+// diffHeaders diffs the headers declared in expected with the same headers
+// of actual, headers in ignore are skipped. Values matching expected
+// placeholders are not reported, missing headers show as <missing>
+func diffHeaders(expected, actual http.Header, ignore []string) string {
+	var wantHeaders, gotHeaders []string
+	for _, name := range sortedKeys(expected) {
+		if slices.ContainsFunc(ignore, func(ignored string) bool { return strings.EqualFold(ignored, name) }) {
+			continue
+		}
+		want := strings.Join(expected.Values(name), ", ")
+		got := "<missing>"
+		if values := actual.Values(name); len(values) > 0 {
+			got = strings.Join(values, ", ")
+		}
+		if matchValue(want, got, anyValue, nil) {
+			want = got
+		}
+		wantHeaders = append(wantHeaders, name+": "+want)
+		gotHeaders = append(gotHeaders, name+": "+got)
+	}
+	return diffLines(strings.Join(wantHeaders, "\n"), strings.Join(gotHeaders, "\n"))
+}
+
+// statusText returns the status like "200 OK"
+func statusText(resp *http.Response) string {
+	if text := http.StatusText(resp.StatusCode); text != "" {
+		return fmt.Sprintf("%d %s", resp.StatusCode, text)
+	}
+	return fmt.Sprint(resp.StatusCode)
+}
+
+// formatQuery writes one sorted key=value pair per line
+func formatQuery(query url.Values) string {
+	var lines []string
+	for _, key := range sortedKeys(query) {
+		for _, value := range query[key] {
+			lines = append(lines, key+"="+value)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatBody indents JSON bodies with sorted keys so they diff
+// semantically, other bodies are only trimmed
+func formatBody(body []byte) string {
+	body = bytes.TrimSpace(body)
+	var v any
+	if err := json.Unmarshal(body, &v); err == nil {
+		if out, err := json.MarshalIndent(v, "", "  "); err == nil {
+			return string(out)
+		}
+	}
+	return string(body)
+}
+
+// formatXML indents XML bodies, other bodies are only trimmed
+func formatXML(body []byte) string {
+	if out, err := canonicalXML(body); err == nil {
+		return string(bytes.TrimSpace(out))
+	}
+	return string(bytes.TrimSpace(body))
+}
+
+// formatForm writes one sorted field=value pair per line
+func formatForm(body []byte) string {
+	if values, err := url.ParseQuery(string(bytes.TrimSpace(body))); err == nil {
+		return formatQuery(values)
+	}
+	return string(bytes.TrimSpace(body))
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,61 @@
+package httpmatter
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPVerify(t *testing.T) {
+	t.Parallel()
+	h := NewHTTP(t, "verify").
+		Verify("Date").
+		Add("request_create_user", "response_create_user").
+		Respond(nil)
+	h.Init()
+	defer h.Destroy()
+	client := h.Client()
+
+	must := require.New(t)
+	req, err := http.NewRequest(http.MethodPost, "https://example.com/users?source=test&team=core",
+		strings.NewReader(`{"admin":false,"name":"John"}`))
+	must.NoError(err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Api-Key", "SuperSecretSerivces")
+	resp, err := client.Do(req)
+	must.NoError(err)
+	must.Equal(201, resp.StatusCode)
+}
+
+func TestVerifyRequestReport(t *testing.T) {
+	must := require.New(t)
+	expected, err := Request("verify", "request_create_user")
+	must.NoError(err)
+
+	actual, err := http.NewRequest(http.MethodPost, "https://example.com/users?team=core&source=prod", nil)
+	must.NoError(err)
+	actual.Header.Set("Content-Type", "application/json")
+	actual.Header.Set("Date", "Tue, 19 Aug 2025 10:00:00 GMT")
+
+	report := verifyRequest(expected, actual, []byte(`{"name":"Jane","admin":false}`), []string{"date"}, queryMatch{})
+	must.Contains(report, "headers differ:")
+	must.Contains(report, "- X-Api-Key: SuperSecretSerivces\n+ X-Api-Key: <missing>")
+	must.NotContains(report, "Date")
+	must.Contains(report, "query differs:")
+	must.Contains(report, "- source=test\n+ source=prod")
+	must.Contains(report, "body differs:")
+	must.Contains(report, `-   "name": "John"`)
+	must.Contains(report, `+   "name": "Jane"`)
+
+	same := verifyRequest(expected, expected.Request, []byte(`{"name":"John","admin":false}`), []string{"Date"}, queryMatch{})
+	must.Empty(same)
+
+	form, err := Request("matching", "request_signup")
+	must.NoError(err)
+	reordered := verifyRequest(form, form.Request, []byte("plan=pro&name=john"), nil, queryMatch{})
+	must.Empty(reordered)
+	report = verifyRequest(form, form.Request, []byte("plan=free&name=john"), nil, queryMatch{})
+	must.Contains(report, "- plan=pro\n+ plan=free")
+}